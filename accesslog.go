@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// File receiving the JSON Lines access log; "-" means stdout and empty disables it
+var accessLogPath string
+
+// Destination of access log lines, or nil when disabled
+var accessLog *accessLogger
+
+// One access log line
+type accessEntry struct {
+	Time      time.Time `json:"time"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Status    int       `json:"status"`
+	Bytes     int64     `json:"bytes"`
+	LatencyMS float64   `json:"latency_ms"`
+	RequestID string    `json:"request_id"`
+}
+
+// Writes one JSON object per line, serializing concurrent requests so lines never interleave
+type accessLogger struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// Opens path for appending, creating it if needed; "-" is stdout and "" returns nil
+func openAccessLog(path string) (*accessLogger, error) {
+	switch path {
+	case "":
+		return nil, nil
+	case "-":
+		return &accessLogger{w: os.Stdout}, nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &accessLogger{w: f}, nil
+}
+
+func (l *accessLogger) log(e accessEntry) {
+	line, err := json.Marshal(e)
+	if err != nil {
+		logger.Error("access log encoding failed", "error", err)
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.w.Write(append(line, '\n')); err != nil {
+		logger.Error("access log write failed", "error", err)
+	}
+}
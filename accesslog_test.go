@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test each request appends one JSON line with the access log fields
+func TestWithLogging_AccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	accessLog = &accessLogger{w: &buf}
+	defer func() { accessLog = nil }()
+
+	w := httptest.NewRecorder()
+	withLogging(healthHandler)(w, httptest.NewRequest("GET", "/healthz", nil))
+
+	line, rest, _ := strings.Cut(buf.String(), "\n")
+	if rest != "" {
+		t.Fatalf("Expected exactly one line, got %q", buf.String())
+	}
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(line), &entry); err != nil {
+		t.Fatalf("Access log line is not JSON: %v", err)
+	}
+	for _, field := range []string{"time", "method", "path", "status", "bytes", "latency_ms", "request_id"} {
+		if _, ok := entry[field]; !ok {
+			t.Errorf("Missing access log field %q in %v", field, entry)
+		}
+	}
+	if entry["method"] != "GET" || entry["path"] != "/healthz" || entry["status"] != float64(w.Code) {
+		t.Errorf("Unexpected access log values: %v", entry)
+	}
+	if entry["bytes"] != float64(w.Body.Len()) {
+		t.Errorf("Expected bytes %d, got %v", w.Body.Len(), entry["bytes"])
+	}
+	if entry["request_id"] != w.Header().Get("X-Request-ID") {
+		t.Errorf("Logged request ID %v does not match the header", entry["request_id"])
+	}
+}
+
+// Test the access log is off when unset and appends to an existing file
+func TestOpenAccessLog(t *testing.T) {
+	if l, err := openAccessLog(""); l != nil || err != nil {
+		t.Errorf("Expected no access log when unset, got %v, %v", l, err)
+	}
+	path := filepath.Join(t.TempDir(), "access.jsonl")
+	os.WriteFile(path, []byte("{}\n"), 0o644)
+	l, err := openAccessLog(path)
+	if err != nil {
+		t.Fatalf("openAccessLog failed: %v", err)
+	}
+	l.log(accessEntry{Method: "GET", Path: "/healthz", Status: 200})
+	data, _ := os.ReadFile(path)
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 2 || !strings.Contains(lines[1], `"path":"/healthz"`) {
+		t.Errorf("Expected the entry appended after the existing line, got %q", data)
+	}
+}
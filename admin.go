@@ -0,0 +1,53 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Bearer token required by /admin endpoints; admin access is disabled when empty
+var adminToken string
+
+// Rejects requests without the configured admin bearer token
+func requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !isAdmin(r) {
+			rejectNonAdmin(w)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// Whether r carries the configured admin bearer token; always false when none is set
+func isAdmin(r *http.Request) bool {
+	given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && adminToken != "" && subtle.ConstantTimeCompare([]byte(given), []byte(adminToken)) == 1
+}
+
+func rejectNonAdmin(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+	respondError(w, 401, errors.New("Missing or invalid admin token"))
+}
+
+// Forces an immediate key rotation, demoting the old key to published-only
+func rotateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		respondError(w, 405, errors.New("use POST"))
+		return
+	}
+	kp, err := rotateKeys(false)
+	if errors.Is(err, errRotationInProgress) {
+		respondError(w, 503, errors.New("Rotation in progress"))
+		return
+	}
+	if err != nil {
+		respondError(w, 500, errors.New("Key rotation failed"))
+		return
+	}
+	if err := respondJSON(w, 200, map[string]string{"kid": kp.Kid}); err != nil {
+		logResponseError(w, r, err)
+	}
+}
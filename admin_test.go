@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func adminRequest(method, target, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	newRouter().ServeHTTP(w, req)
+	return w
+}
+
+// Test a successful rotation publishes the new kid and demotes the old key
+func TestRotateHandler(t *testing.T) {
+	adminToken = "s3cret"
+	defer func() { adminToken = "" }()
+	old, _ := generateKeyPair(time.Now().Add(time.Hour), 2048)
+	validKey = old
+	setKeyRing([]*KeyPair{old})
+
+	w := adminRequest("POST", "/admin/rotate", "s3cret")
+	if w.Code != 200 {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	var resp map[string]string
+	envelopeData(t, w.Body.Bytes(), &resp)
+	if resp["kid"] == "" || resp["kid"] != validKey.Kid || resp["kid"] == old.Kid {
+		t.Fatalf("Expected new signing kid, got %v", resp)
+	}
+
+	w = httptest.NewRecorder()
+	jwksHandler(w, httptest.NewRequest("GET", "/.well-known/jwks.json", nil))
+	var jwks JWKS
+	json.Unmarshal(w.Body.Bytes(), &jwks)
+	kids := map[string]bool{}
+	for _, k := range jwks.Keys {
+		kids[k.Kid] = true
+	}
+	if !kids[resp["kid"]] || !kids[old.Kid] {
+		t.Errorf("Expected new and demoted kids in JWKS, got %v", kids)
+	}
+}
+
+// Test missing or wrong admin tokens are rejected
+func TestRotateHandler_Unauthorized(t *testing.T) {
+	adminToken = "s3cret"
+	defer func() { adminToken = "" }()
+	for _, token := range []string{"", "wrong"} {
+		if w := adminRequest("POST", "/admin/rotate", token); w.Code != 401 {
+			t.Errorf("Expected 401 for token %q, got %d", token, w.Code)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Default token lifetime per requested audience; others use tokenTTL
+var audienceTTLs map[string]time.Duration
+
+// Default TTL for a token requested for aud
+func ttlForAudience(aud string) time.Duration {
+	if ttl, ok := audienceTTLs[aud]; ok {
+		return ttl
+	}
+	return tokenTTL
+}
+
+// Whether a client may request aud: only the configured -audience values and
+// -audience-ttl keys, so callers cannot mint tokens for arbitrary APIs
+func audienceAllowed(aud string) bool {
+	_, ok := audienceTTLs[aud]
+	return ok || slices.Contains(audience, aud)
+}
+
+// Registers a comma-separated name=duration flag such as "short=5m,long=1h", resetting *p
+func durationMapVar(fs *flag.FlagSet, p *map[string]time.Duration, name, usage string) {
+	*p = nil
+	fs.Func(name, usage, func(s string) error {
+		m := map[string]time.Duration{}
+		for _, item := range splitList(s) {
+			key, value, ok := strings.Cut(item, "=")
+			if !ok || key == "" {
+				return fmt.Errorf("expected name=duration, got %q", item)
+			}
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return err
+			}
+			m[key] = d
+		}
+		*p = m
+		return nil
+	})
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Log in requesting an audience and return the minted claims
+func loginForAudience(t *testing.T, aud string) map[string]any {
+	t.Helper()
+	body, _ := json.Marshal(Credentials{Username: "user123", Password: "password123", Audience: aud})
+	w := httptest.NewRecorder()
+	authHandler(w, httptest.NewRequest("POST", "/auth", bytes.NewReader(body)))
+	if w.Code != 200 {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	return mintedClaims(t, w.Body.Bytes())
+}
+
+// Test each audience gets its configured lifetime and one without a TTL the default
+func TestAuthHandler_AudienceTTL(t *testing.T) {
+	if err := parseTestFlags(t, "-audience", "other", "-audience-ttl", "short=5m,long=1h", "-token-ttl", "30m"); err != nil {
+		t.Fatal(err)
+	}
+	validKey, _ = generateKeyPair(time.Now().Add(2*time.Hour), 2048)
+
+	for aud, want := range map[string]time.Duration{"short": 5 * time.Minute, "long": time.Hour, "other": 30 * time.Minute} {
+		claims := loginForAudience(t, aud)
+		if got := time.Duration(claims["exp"].(float64)-claims["iat"].(float64)) * time.Second; got != want {
+			t.Errorf("Audience %q: expected %v token, got %v", aud, want, got)
+		}
+		if a, _ := claims["aud"].([]any); len(a) != 1 || a[0] != aud {
+			t.Errorf("Audience %q: expected aud claim [%s], got %v", aud, aud, claims["aud"])
+		}
+	}
+}
+
+// Test an audience outside -audience and -audience-ttl is refused
+func TestAuthHandler_AudienceNotConfigured(t *testing.T) {
+	if err := parseTestFlags(t, "-audience", "api", "-audience-ttl", "short=5m"); err != nil {
+		t.Fatal(err)
+	}
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+
+	body, _ := json.Marshal(Credentials{Username: "user123", Password: "password123", Audience: "payments"})
+	w := httptest.NewRecorder()
+	authHandler(w, httptest.NewRequest("POST", "/auth", bytes.NewReader(body)))
+	if p := assertEnvelopeError(t, w, 400); !strings.Contains(p.Detail, `"payments"`) {
+		t.Errorf("Expected the audience named in the detail, got %q", p.Detail)
+	}
+	w = httptest.NewRecorder()
+	authHandler(w, loginRequest("/auth?audience=payments", "user123", "password123"))
+	assertEnvelopeError(t, w, 400)
+}
+
+// Test malformed -audience-ttl values are rejected
+func TestParseFlags_AudienceTTLInvalid(t *testing.T) {
+	for _, v := range []string{"short", "short=soon", "short=48h"} {
+		if err := parseTestFlags(t, "-audience-ttl", v); err == nil {
+			t.Errorf("Expected %q to be rejected", v)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// Metadata recorded for each issued token; never the token itself
+type AuditEntry struct {
+	JTI      string `json:"jti"`
+	Sub      string `json:"sub"`
+	IssuedAt int64  `json:"iat"`
+	Exp      int64  `json:"exp"`
+	SourceIP string `json:"source_ip,omitempty"`
+}
+
+// Fixed-size ring buffer of the most recent audit entries
+type auditLog struct {
+	mu      sync.Mutex
+	entries []AuditEntry
+	next    int
+	full    bool
+}
+
+// Number of entries retained by the audit log
+var auditSize = 1000
+
+// Audit log of issued tokens; sized from auditSize at startup
+var audit = newAuditLog(1000)
+
+func newAuditLog(size int) *auditLog {
+	return &auditLog{entries: make([]AuditEntry, size)}
+}
+
+func (a *auditLog) record(e AuditEntry) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.entries[a.next] = e
+	a.next = (a.next + 1) % len(a.entries)
+	if a.next == 0 {
+		a.full = true
+	}
+}
+
+// Returns up to n of the newest entries, oldest first; n <= 0 returns all
+func (a *auditLog) last(n int) []AuditEntry {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	out := append([]AuditEntry{}, a.entries[:a.next]...)
+	if a.full {
+		out = append(append([]AuditEntry{}, a.entries[a.next:]...), out...)
+	}
+	if n > 0 && n < len(out) {
+		out = out[len(out)-n:]
+	}
+	return out
+}
+
+type sourceIPKey struct{}
+
+// Returns the request context annotated with the client IP for the audit log
+func withSourceIP(r *http.Request) context.Context {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return context.WithValue(r.Context(), sourceIPKey{}, ip)
+}
+
+func sourceIP(ctx context.Context) string {
+	ip, _ := ctx.Value(sourceIPKey{}).(string)
+	return ip
+}
+
+// Lists recent token issuances, optionally limited by ?limit=N
+func auditHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		respondError(w, 405, errors.New("use GET"))
+		return
+	}
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			respondError(w, 400, errors.New("limit must be a positive integer"))
+			return
+		}
+		limit = n
+	}
+	if err := respondJSON(w, 200, map[string][]AuditEntry{"entries": audit.last(limit)}); err != nil {
+		logResponseError(w, r, err)
+	}
+}
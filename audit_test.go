@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// Fetch the audit listing as the admin
+func auditEntries(t *testing.T, target string) []AuditEntry {
+	t.Helper()
+	w := adminRequest("GET", target, "s3cret")
+	if w.Code != 200 {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp map[string][]AuditEntry
+	envelopeData(t, w.Body.Bytes(), &resp)
+	return resp["entries"]
+}
+
+// Test issued tokens appear in the audit log in order, without the token
+func TestAuditHandler(t *testing.T) {
+	adminToken = "s3cret"
+	defer func() { adminToken = "" }()
+	audit = newAuditLog(10)
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	keyRing = []*KeyPair{validKey}
+
+	first := unverifiedClaims(t, mintToken(t, "/auth"))
+	second := unverifiedClaims(t, mintToken(t, "/auth"))
+
+	entries := auditEntries(t, "/admin/audit")
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+	for i, claims := range []map[string]any{first, second} {
+		e := entries[i]
+		if e.JTI != claims["jti"] || e.Sub != "user123" || e.Exp != int64(claims["exp"].(float64)) || e.IssuedAt != int64(claims["iat"].(float64)) {
+			t.Errorf("Entry %d does not match token: %+v", i, e)
+		}
+		if e.SourceIP != "192.0.2.1" {
+			t.Errorf("Expected httptest source IP, got %q", e.SourceIP)
+		}
+	}
+	if limited := auditEntries(t, "/admin/audit?limit=1"); len(limited) != 1 || limited[0].JTI != second["jti"] {
+		t.Errorf("Expected only the newest entry, got %+v", limited)
+	}
+}
+
+// Test the ring buffer keeps only the newest entries once full
+func TestAuditLog_Wraps(t *testing.T) {
+	a := newAuditLog(2)
+	for _, jti := range []string{"a", "b", "c"} {
+		a.record(AuditEntry{JTI: jti})
+	}
+	got := a.last(0)
+	if len(got) != 2 || got[0].JTI != "b" || got[1].JTI != "c" {
+		t.Errorf("Expected [b c], got %+v", got)
+	}
+}
+
+// Test the audit endpoint requires the admin token
+func TestAuditHandler_Unauthorized(t *testing.T) {
+	adminToken = "s3cret"
+	defer func() { adminToken = "" }()
+	assertEnvelopeError(t, adminRequest("GET", "/admin/audit", "wrong"), 401)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Largest batch /auth/batch will sign in one request
+var maxBatchCount = 100
+
+// Batch issuance request; count, when given, must match the number of subjects
+type BatchRequest struct {
+	Count    int      `json:"count"`
+	Subjects []string `json:"subjects"`
+}
+
+// Issues one signed token per subject for load-testing and seeding
+func batchAuthHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		respondError(w, 405, errors.New("use POST"))
+		return
+	}
+	var req BatchRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	n := len(req.Subjects)
+	switch {
+	case n == 0:
+		respondError(w, 400, errors.New("At least one subject is required"))
+		return
+	case req.Count != 0 && req.Count != n:
+		respondError(w, 400, fmt.Errorf("count %d does not match %d subjects", req.Count, n))
+		return
+	case n > maxBatchCount:
+		respondError(w, 400, fmt.Errorf("batch of %d exceeds the maximum of %d", n, maxBatchCount))
+		return
+	}
+	now := nowFunc()
+	kp, ok := signingKeyAt(now)
+	if !ok {
+		writeNoSigningKey(w, r)
+		return
+	}
+
+	exp := tokenExpiry(now, tokenTTL, kp)
+	tokens := make([]string, 0, n)
+	for _, sub := range req.Subjects {
+		token, err := issueToken(withRequestTime(withSourceIP(r), now), kp, sub, audience, exp)
+		if err != nil {
+			writeSignError(w, r, err)
+			return
+		}
+		tokens = append(tokens, token)
+	}
+	if err := respondJSON(w, 200, map[string][]string{"tokens": tokens}); err != nil {
+		logResponseError(w, r, err)
+	}
+}
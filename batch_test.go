@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func batchRequest(body BatchRequest) *httptest.ResponseRecorder {
+	data, _ := json.Marshal(body)
+	w := httptest.NewRecorder()
+	batchAuthHandler(w, httptest.NewRequest("POST", "/auth/batch", bytes.NewReader(data)))
+	return w
+}
+
+// Test a small batch yields one token per subject with unique jtis
+func TestBatchAuthHandler(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	w := batchRequest(BatchRequest{Count: 3, Subjects: []string{"alice", "bob", "carol"}})
+	if w.Code != 200 {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	var resp map[string][]string
+	envelopeData(t, w.Body.Bytes(), &resp)
+	if len(resp["tokens"]) != 3 {
+		t.Fatalf("Expected 3 tokens, got %d", len(resp["tokens"]))
+	}
+	jtis := map[any]bool{}
+	for i, token := range resp["tokens"] {
+		claims := unverifiedClaims(t, token)
+		if sub, _ := claims.GetSubject(); sub != []string{"alice", "bob", "carol"}[i] {
+			t.Errorf("Token %d has sub %q", i, sub)
+		}
+		jtis[claims["jti"]] = true
+	}
+	if len(jtis) != 3 {
+		t.Errorf("Expected unique jtis, got %v", jtis)
+	}
+}
+
+// Test exceeding the maximum batch size is rejected
+func TestBatchAuthHandler_TooMany(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	subjects := make([]string, maxBatchCount+1)
+	for i := range subjects {
+		subjects[i] = fmt.Sprintf("user%d", i)
+	}
+	if w := batchRequest(BatchRequest{Count: len(subjects), Subjects: subjects}); w.Code != 400 {
+		t.Errorf("Expected 400, got %d", w.Code)
+	}
+}
+
+// Test the batch endpoint requires the admin token
+func TestBatchAuthHandler_RequiresAdmin(t *testing.T) {
+	if w := adminRequest("POST", "/auth/batch", ""); w.Code != 401 {
+		t.Errorf("Expected 401, got %d", w.Code)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Upper bound on how long clients may cache the JWKS
+const maxJWKSCacheAge = 300 * time.Second
+
+// Strong ETag derived from the sorted set of published kids
+func jwksETag(keys []*KeyPair) string {
+	kids := make([]string, len(keys))
+	for i, kp := range keys {
+		kids[i] = kp.Kid
+	}
+	sort.Strings(kids)
+	sum := sha256.Sum256([]byte(strings.Join(kids, ",")))
+	return `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+}
+
+// Cache lifetime bounded by the soonest key leaving the JWKS and maxJWKSCacheAge
+func jwksMaxAge(keys []*KeyPair, now time.Time) time.Duration {
+	age := maxJWKSCacheAge
+	for _, kp := range keys {
+		if left := kp.ExpiresAt.Add(jwksGrace).Sub(now); left < age {
+			age = left
+		}
+	}
+	if len(keys) == 0 || age < 0 {
+		return 0
+	}
+	return age
+}
+
+// Reports whether an If-None-Match header matches the current ETag
+func etagMatches(r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func setJWKSCacheHeaders(w http.ResponseWriter, etag string, maxAge time.Duration) {
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+	w.Header().Set("ETag", etag)
+	w.Header().Add("Vary", "Accept")
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Test JWKS sets caching headers bounded by the cap
+func TestJWKSHandler_CacheHeaders(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	keyRing = []*KeyPair{validKey}
+	w := httptest.NewRecorder()
+	jwksHandler(w, httptest.NewRequest("GET", "/.well-known/jwks.json", nil))
+
+	if cc := w.Header().Get("Cache-Control"); cc != "public, max-age=300" {
+		t.Errorf("Unexpected Cache-Control: %q", cc)
+	}
+	if w.Header().Get("ETag") == "" {
+		t.Error("Expected ETag header")
+	}
+}
+
+// Test max-age shrinks to the soonest key expiry
+func TestJWKSMaxAge_SoonestExpiry(t *testing.T) {
+	now := time.Now()
+	keys := []*KeyPair{{ExpiresAt: now.Add(time.Hour)}, {ExpiresAt: now.Add(90 * time.Second)}}
+	if age := jwksMaxAge(keys, now); age != 90*time.Second {
+		t.Errorf("Expected 90s, got %v", age)
+	}
+}
+
+// Test matching If-None-Match yields 304
+func TestJWKSHandler_ETagMatch(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	keyRing = []*KeyPair{validKey}
+	w := httptest.NewRecorder()
+	jwksHandler(w, httptest.NewRequest("GET", "/.well-known/jwks.json", nil))
+	etag := w.Header().Get("ETag")
+
+	req := httptest.NewRequest("GET", "/.well-known/jwks.json", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	jwksHandler(w, req)
+	if w.Code != 304 || w.Body.Len() != 0 {
+		t.Errorf("Expected empty 304, got %d with %q", w.Code, w.Body.String())
+	}
+}
+
+// Test stale If-None-Match yields the full body
+func TestJWKSHandler_ETagMismatch(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	keyRing = []*KeyPair{validKey}
+	req := httptest.NewRequest("GET", "/.well-known/jwks.json", nil)
+	req.Header.Set("If-None-Match", `"stale"`)
+	w := httptest.NewRecorder()
+	jwksHandler(w, req)
+	if w.Code != 200 || w.Body.Len() == 0 {
+		t.Errorf("Expected 200 with body, got %d", w.Code)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"strings"
+)
+
+// Static claims added to every issued token; the server's own claims always win
+var customClaims map[string]any
+
+// Registers a flag taking a JSON object of claims, resetting *p
+func claimsVar(fs *flag.FlagSet, p *map[string]any, name, usage string) {
+	*p = nil
+	fs.Func(name, usage, func(s string) error {
+		var m map[string]any
+		dec := json.NewDecoder(strings.NewReader(s))
+		dec.UseNumber()
+		if err := dec.Decode(&m); err != nil {
+			return err
+		}
+		if m == nil {
+			return errors.New("expected a JSON object")
+		}
+		*p = m
+		return nil
+	})
+}
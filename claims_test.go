@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// Test custom claims reach the token without overriding reserved ones
+func TestAuthHandler_CustomClaims(t *testing.T) {
+	if err := parseTestFlags(t, "-claims", `{"scope":"read","role":"user","exp":1,"sub":"admin"}`); err != nil {
+		t.Fatal(err)
+	}
+	validKey, _ = generateKeyPair(time.Now().Add(2*time.Hour), 2048)
+
+	claims := unverifiedClaims(t, mintToken(t, "/auth"))
+	if claims["scope"] != "read" || claims["role"] != "user" {
+		t.Errorf("Expected custom claims, got %v", claims)
+	}
+	if exp := claims["exp"].(float64); exp <= float64(time.Now().Unix()) {
+		t.Errorf("Expected exp not to be overridden, got %v", exp)
+	}
+	if claims["sub"] != "user123" {
+		t.Errorf("Expected sub not to be overridden, got %v", claims["sub"])
+	}
+}
+
+// Test malformed -claims values are rejected
+func TestParseFlags_InvalidClaims(t *testing.T) {
+	for _, v := range []string{`{"scope":`, `["read"]`, `null`} {
+		if err := parseTestFlags(t, "-claims", v); err == nil {
+			t.Errorf("Expected -claims %s to be rejected", v)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+// Settings that may be supplied by a -config JSON file; flags override them
+type Config struct {
+	RSABits          int                     `json:"rsa_bits"`
+	RotationInterval jsonDuration            `json:"rotation_interval"`
+	Issuer           string                  `json:"issuer"`
+	Audience         []string                `json:"audience"`
+	AudienceTTLs     map[string]jsonDuration `json:"audience_ttls"`
+	TokenTTL         jsonDuration            `json:"token_ttl"`
+	ListenAddr       string                  `json:"listen_addr"`
+	Tenants          map[string]string       `json:"tenants"`
+}
+
+// Duration encoded in JSON as a time.ParseDuration string such as "12h"
+type jsonDuration time.Duration
+
+func (d *jsonDuration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"1h\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = jsonDuration(v)
+	return nil
+}
+
+// Server settings not tied to an existing subsystem
+var (
+	listenAddr       = ":8080"
+	rotationInterval time.Duration
+	tokenTTL         = defaultTokenTTL
+	drainTimeout     = 10 * time.Second
+	// http.Server timeouts guarding against slow or idle clients
+	readHeaderTimeout = 5 * time.Second
+	readTimeout       = 10 * time.Second
+	writeTimeout      = 30 * time.Second
+	idleTimeout       = 120 * time.Second
+)
+
+func readConfigFile(path string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Copies file values into the globals, skipping fields whose flag was set explicitly
+func (c Config) apply(setFlags map[string]bool) {
+	if c.RSABits != 0 && !setFlags["rsa-bits"] {
+		rsaBits = c.RSABits
+	}
+	if c.RotationInterval != 0 && !setFlags["rotation-interval"] {
+		rotationInterval = time.Duration(c.RotationInterval)
+	}
+	if c.Issuer != "" && !setFlags["issuer"] {
+		issuer = c.Issuer
+	}
+	if c.Audience != nil && !setFlags["audience"] {
+		audience = c.Audience
+	}
+	if c.AudienceTTLs != nil && !setFlags["audience-ttl"] {
+		audienceTTLs = map[string]time.Duration{}
+		for aud, ttl := range c.AudienceTTLs {
+			audienceTTLs[aud] = time.Duration(ttl)
+		}
+	}
+	if c.TokenTTL != 0 && !setFlags["token-ttl"] {
+		tokenTTL = time.Duration(c.TokenTTL)
+	}
+	if c.ListenAddr != "" && !setFlags["addr"] {
+		listenAddr = c.ListenAddr
+	}
+	if c.Tenants != nil && !setFlags["tenants"] {
+		tenantIssuers = c.Tenants
+	}
+}
+
+// Registers a comma-separated list flag, resetting *p to def
+func listVar(fs *flag.FlagSet, p *[]string, name, def, usage string) {
+	*p = splitList(def)
+	fs.Func(name, usage, func(s string) error {
+		*p = splitList(s)
+		return nil
+	})
+}
+
+// Parses command-line flags and an optional -config file into the server settings
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	fs.StringVar(&listenAddr, "addr", ":8080", "listen address (falls back to $PORT)")
+	fs.StringVar(&signingAlg, "alg", "RS256", "signing algorithm: RS256, PS256 (RSASSA-PSS) or ES256")
+	fs.IntVar(&rsaBits, "rsa-bits", 2048, "RSA key size in bits (at least -min-rsa-bits)")
+	fs.IntVar(&minRSABits, "min-rsa-bits", rsaBitsFloor, "smallest RSA modulus accepted for any generated or loaded key (can only be raised above 2048)")
+	fs.StringVar(&issuer, "issuer", "http://localhost:8080", "issuer identifier used for the iss claim and discovery")
+	listVar(fs, &audience, "audience", "", "comma-separated aud claim values")
+	durationMapVar(fs, &audienceTTLs, "audience-ttl", "comma-separated audience=ttl defaults for tokens requesting that audience")
+	claimsVar(fs, &customClaims, "claims", "JSON object of static claims added to every token (iss, sub, aud, exp, nbf, iat and jti cannot be overridden)")
+	stringMapVar(fs, &tenantIssuers, "tenants", "comma-separated tenant=issuer pairs, each served under /tenants/{tenant}/ with its own keys")
+	fs.StringVar(&baseURL, "base-url", "", "public base URL for endpoint URLs (defaults to -issuer)")
+	fs.BoolVar(&authJWKSURI, "auth-jwks-uri", false, "include the absolute JWKS URL as jwks_uri in /auth responses")
+	fs.StringVar(&tokenTyp, "token-typ", "JWT", "typ header of issued tokens, e.g. JWT or at+jwt for the RFC 9068 access-token profile")
+	fs.DurationVar(&tokenTTL, "token-ttl", defaultTokenTTL, "default lifetime of issued tokens")
+	fs.DurationVar(&signTimeout, "sign-timeout", 5*time.Second, "deadline for signing a single token")
+	fs.DurationVar(&nbfSkew, "nbf-skew", 0, "set nbf this far before iat to tolerate verifier clock skew")
+	fs.DurationVar(&introspectLeeway, "introspect-leeway", 60*time.Second, "clock skew tolerated on exp/nbf when introspecting tokens")
+	fs.DurationVar(&refreshTTL, "refresh-ttl", 7*24*time.Hour, "lifetime of refresh tokens")
+	fs.Float64Var(&expiryJitter, "expiry-jitter", 0, "randomly spread new key expiries by up to ± this percentage of their lifetime")
+	fs.DurationVar(&rotationInterval, "rotation-interval", 0, "how often to rotate the signing key (0 disables rotation)")
+	fs.IntVar(&rotateAfterSigns, "rotate-after-signs", 0, "rotate the signing key once /auth has signed this many tokens with it (0 disables)")
+	fs.DurationVar(&pruneInterval, "prune-interval", 10*time.Minute, "how often expired keys are pruned from the store between rotations (0 disables)")
+	fs.StringVar(&rotationWebhook, "rotation-webhook", "", "URL that receives a POST with the added and removed kids and the new JWKS after each rotation")
+	fs.IntVar(&webhookAttempts, "webhook-attempts", 3, "delivery attempts per rotation webhook before giving up")
+	fs.DurationVar(&webhookBackoff, "webhook-backoff", time.Second, "delay before the first webhook retry, doubled after each failure")
+	fs.IntVar(&maxRetainedKeys, "max-keys", 0, "most signing keys kept for verification; beyond it the earliest-expiring are dropped (0 for no limit)")
+	fs.DurationVar(&jwksGrace, "jwks-grace", 0, "how long expired keys remain published in the JWKS")
+	fs.BoolVar(&jwksAlias, "jwks-alias", true, "serve the JWKS at /jwks.json and redirect /jwks to /.well-known/jwks.json")
+	fs.StringVar(&keyFile, "key-file", "", "PEM file with a PKCS#1 or PKCS#8 RSA private key to sign with")
+	fs.StringVar(&usersFile, "users-file", "", "htpasswd-style file of username:bcrypt-hash lines")
+	fs.BoolVar(&demoUser, "demo-user", false, "seed the well-known user123/password123 account (local development only)")
+	fs.StringVar(&jweKeyFile, "jwe-key", "", "PEM public key (SPKI); /auth then returns tokens encrypted to it as nested JWS-in-JWE")
+	fs.StringVar(&jweAlg, "jwe-alg", string(jose.RSA_OAEP_256), "JWE key management algorithm: RSA-OAEP-256, RSA-OAEP, ECDH-ES or ECDH-ES+A256KW")
+	fs.StringVar(&jweEnc, "jwe-enc", string(jose.A256GCM), "JWE content encryption: A128GCM, A256GCM, A128CBC-HS256 or A256CBC-HS512")
+	fs.BoolVar(&publishEncKey, "enc-key", false, "also publish an RSA-OAEP-256 encryption key (use \"enc\")")
+	fs.BoolVar(&emitKeyOps, "key-ops", false, "publish key_ops ([\"verify\"] or [\"encrypt\"]) on each JWK")
+	fs.BoolVar(&emitX5C, "emit-x5c", false, "publish a self-signed certificate per key as x5c and x5t#S256")
+	fs.StringVar(&kidMode, "kid-mode", "uuid", "how key IDs are assigned: uuid or thumbprint (RFC 7638)")
+	listVar(fs, &corsOrigins, "cors-origins", "*", "comma-separated origins allowed to fetch JWKS and discovery")
+	listVar(fs, &authCORSOrigins, "auth-cors-origins", "", "comma-separated origins allowed to call /auth")
+	fs.DurationVar(&drainTimeout, "drain-timeout", 10*time.Second, "time allowed for in-flight requests on shutdown")
+	fs.DurationVar(&readHeaderTimeout, "read-header-timeout", 5*time.Second, "time allowed to read request headers")
+	fs.DurationVar(&readTimeout, "read-timeout", 10*time.Second, "time allowed to read an entire request")
+	fs.DurationVar(&writeTimeout, "write-timeout", 30*time.Second, "time allowed to write a response")
+	fs.DurationVar(&idleTimeout, "idle-timeout", 120*time.Second, "how long idle keep-alive connections stay open")
+	fs.StringVar(&tlsCertFile, "tls-cert", "", "TLS certificate file; enables HTTPS together with -tls-key")
+	fs.StringVar(&tlsKeyFile, "tls-key", "", "TLS private key file; enables HTTPS together with -tls-cert")
+	fs.BoolVar(&secureHeaders, "secure-headers", false, "send HSTS (HTTPS only), nosniff and Referrer-Policy headers (default on with -tls-cert)")
+	fs.BoolVar(&enableExpiredEndpoint, "enable-expired-endpoint", false, "serve /auth?expired=true, which signs tokens with an already-expired key (testing only)")
+	fs.StringVar(&accessLogPath, "access-log", "", "file to append a JSON Lines access log to, one object per request (- for stdout)")
+	fs.BoolVar(&debugEndpoints, "debug", false, "serve GET /debug/decode, which decodes a JWT without verifying it (never enable in production)")
+	fs.BoolVar(&genKeyOnly, "gen-key", false, "print a new RSA key (kid, PKCS#8 private and SPKI public PEM) for -key-file and exit")
+	fs.BoolVar(&dumpJWKSOnly, "dump-jwks", false, "generate keys, print the JWKS to stdout and exit without serving")
+	fs.BoolVar(&skipSelfTest, "skip-selftest", false, "skip signing and verifying a test token at startup")
+	fs.IntVar(&keygenAttempts, "keygen-attempts", 3, "attempts at generating a key before giving up")
+	fs.DurationVar(&keygenBackoff, "keygen-backoff", 100*time.Millisecond, "delay before the first key generation retry, doubled after each failure")
+	fs.IntVar(&keyPoolSize, "key-pool", 1, "keys pre-generated in the background so rotation need not wait for key generation (0 disables)")
+	fs.IntVar(&maxKeygen, "max-keygen", 1, "maximum concurrent key generations")
+	fs.IntVar(&quotaLimit, "quota-limit", 0, "maximum tokens /auth issues per subject within -quota-window (0 disables)")
+	fs.DurationVar(&quotaWindow, "quota-window", time.Hour, "rolling window for -quota-limit")
+	fs.IntVar(&auditSize, "audit-size", 1000, "number of issued-token records kept for /admin/audit")
+	fs.IntVar(&maxBatchCount, "max-batch", 100, "maximum tokens issued by one /auth/batch request")
+	regexpVar(fs, &subPattern, "sub-pattern", defaultSubPattern, "regular expression every token subject must match; others get 400")
+	fs.StringVar(&trustSubjectHeader, "trust-subject-header", "", "header (e.g. X-Auth-Subject) whose value /auth uses as the subject without checking credentials; only for use behind a gateway that sets it")
+	fs.StringVar(&adminToken, "admin-token", "", "bearer token for /admin endpoints (disabled when empty); env:NAME or @file reads it from the environment or a file")
+	fs.StringVar(&keyPassphraseFlag, "key-passphrase", "", "passphrase for private keys at rest as env:NAME or @file (defaults to $"+passphraseEnv+")")
+	configPath := fs.String("config", "", "JSON config file; explicit flags override its values")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	setFlags := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { setFlags[f.Name] = true })
+	if !setFlags["secure-headers"] {
+		secureHeaders = tlsCertFile != ""
+	}
+	// PaaS platforms hand out the port via $PORT; it ranks just above the default
+	if port := os.Getenv("PORT"); port != "" && !setFlags["addr"] {
+		listenAddr = ":" + port
+	}
+	if *configPath != "" {
+		cfg, err := readConfigFile(*configPath)
+		if err != nil {
+			return err
+		}
+		cfg.apply(setFlags)
+	}
+	for name, p := range map[string]*string{"admin-token": &adminToken, "key-passphrase": &keyPassphraseFlag} {
+		v, err := resolveSecret(*p)
+		if err != nil {
+			return fmt.Errorf("-%s: %w", name, err)
+		}
+		*p = v
+	}
+	keyPassphrase = []byte(cmp.Or(keyPassphraseFlag, os.Getenv(passphraseEnv)))
+	return validateSettings()
+}
+
+// Rejects settings the server cannot run with
+func validateSettings() error {
+	if !slices.Contains(signingAlgs, signingAlg) {
+		return fmt.Errorf("unsupported alg %q", signingAlg)
+	}
+	if kidMode != "uuid" && kidMode != "thumbprint" {
+		return fmt.Errorf("unsupported kid mode %q", kidMode)
+	}
+	if minRSABits < rsaBitsFloor {
+		return fmt.Errorf("min rsa bits %d must be at least %d", minRSABits, rsaBitsFloor)
+	}
+	if rsaBits < minRSABits {
+		return fmt.Errorf("rsa key size %d is below the minimum of %d bits", rsaBits, minRSABits)
+	}
+	if issuer == "" {
+		return errors.New("issuer is required")
+	}
+	for name, iss := range tenantIssuers {
+		if iss == "" {
+			return fmt.Errorf("tenant %q needs an issuer", name)
+		}
+		if strings.Contains(name, "/") {
+			return fmt.Errorf("tenant name %q must not contain '/'", name)
+		}
+	}
+	if listenAddr == "" {
+		return errors.New("listen address is required")
+	}
+	if tokenTyp == "" {
+		return errors.New("token typ must not be empty")
+	}
+	if tokenTTL <= 0 || tokenTTL > maxTokenTTL {
+		return fmt.Errorf("token ttl %v must be between 0 and %v", tokenTTL, maxTokenTTL)
+	}
+	for aud, ttl := range audienceTTLs {
+		if ttl <= 0 || ttl > maxTokenTTL {
+			return fmt.Errorf("ttl %v for audience %q must be between 0 and %v", ttl, aud, maxTokenTTL)
+		}
+	}
+	if (tlsCertFile == "") != (tlsKeyFile == "") {
+		return errors.New("-tls-cert and -tls-key must be set together")
+	}
+	for name, d := range map[string]time.Duration{
+		"read header timeout": readHeaderTimeout,
+		"read timeout":        readTimeout,
+		"write timeout":       writeTimeout,
+		"idle timeout":        idleTimeout,
+	} {
+		if d <= 0 {
+			return fmt.Errorf("%s %s must be positive", name, d)
+		}
+	}
+	if signTimeout <= 0 {
+		return fmt.Errorf("sign timeout %v must be positive", signTimeout)
+	}
+	if nbfSkew < 0 {
+		return fmt.Errorf("nbf skew %v must not be negative", nbfSkew)
+	}
+	if introspectLeeway < 0 {
+		return fmt.Errorf("introspect leeway %s must not be negative", introspectLeeway)
+	}
+	if refreshTTL <= 0 {
+		return fmt.Errorf("refresh ttl %v must be positive", refreshTTL)
+	}
+	if quotaLimit < 0 {
+		return fmt.Errorf("quota limit %d must not be negative", quotaLimit)
+	}
+	if quotaWindow <= 0 {
+		return fmt.Errorf("quota window %s must be positive", quotaWindow)
+	}
+	if auditSize < 1 {
+		return fmt.Errorf("audit size %d must be at least 1", auditSize)
+	}
+	if expiryJitter < 0 || expiryJitter >= 100 {
+		return fmt.Errorf("expiry jitter %g%% must be in [0, 100)", expiryJitter)
+	}
+	if keygenAttempts < 1 {
+		return fmt.Errorf("keygen attempts %d must be at least 1", keygenAttempts)
+	}
+	if keygenBackoff < 0 {
+		return fmt.Errorf("keygen backoff %s must not be negative", keygenBackoff)
+	}
+	if rotateAfterSigns < 0 {
+		return fmt.Errorf("rotate after signs %d must not be negative", rotateAfterSigns)
+	}
+	if maxRetainedKeys < 0 {
+		return fmt.Errorf("max keys %d must not be negative", maxRetainedKeys)
+	}
+	if keyPoolSize < 0 {
+		return fmt.Errorf("key pool size %d must not be negative", keyPoolSize)
+	}
+	if maxKeygen < 1 {
+		return fmt.Errorf("max keygen %d must be at least 1", maxKeygen)
+	}
+	if rotationInterval < 0 {
+		return fmt.Errorf("rotation interval %v must not be negative", rotationInterval)
+	}
+	if pruneInterval < 0 {
+		return fmt.Errorf("prune interval %v must not be negative", pruneInterval)
+	}
+	if rotationWebhook != "" {
+		if u, err := url.Parse(rotationWebhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("rotation webhook %q must be an http or https URL", rotationWebhook)
+		}
+	}
+	if !slices.Contains(jweAlgs, jose.KeyAlgorithm(jweAlg)) {
+		return fmt.Errorf("unsupported jwe alg %q", jweAlg)
+	}
+	if !slices.Contains(jweEncs, jose.ContentEncryption(jweEnc)) {
+		return fmt.Errorf("unsupported jwe enc %q", jweEnc)
+	}
+	if webhookAttempts < 1 {
+		return fmt.Errorf("webhook attempts %d must be at least 1", webhookAttempts)
+	}
+	if webhookBackoff < 0 {
+		return fmt.Errorf("webhook backoff %s must not be negative", webhookBackoff)
+	}
+	return nil
+}
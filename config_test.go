@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// Parse args on a fresh flag set, restoring defaults when the test ends
+func parseTestFlags(t *testing.T, args ...string) error {
+	t.Helper()
+	t.Cleanup(func() { parseFlags(flag.NewFlagSet("reset", flag.ContinueOnError), nil) })
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return parseFlags(fs, args)
+}
+
+func writeConfig(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(body), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// Test every field of a full config file is applied
+func TestParseFlags_FullConfig(t *testing.T) {
+	path := writeConfig(t, `{
+		"rsa_bits": 3072,
+		"rotation_interval": "12h",
+		"issuer": "https://issuer.example",
+		"audience": ["api-a", "api-b"],
+		"token_ttl": "15m",
+		"listen_addr": "127.0.0.1:9090"
+	}`)
+	if err := parseTestFlags(t, "-config", path); err != nil {
+		t.Fatalf("parseFlags failed: %v", err)
+	}
+	if rsaBits != 3072 || rotationInterval != 12*time.Hour || issuer != "https://issuer.example" ||
+		!slices.Equal(audience, []string{"api-a", "api-b"}) || tokenTTL != 15*time.Minute || listenAddr != "127.0.0.1:9090" {
+		t.Errorf("Config not applied: bits=%d rot=%v iss=%q aud=%v ttl=%v addr=%q",
+			rsaBits, rotationInterval, issuer, audience, tokenTTL, listenAddr)
+	}
+}
+
+// Test flags override file values and defaults fill the gaps
+func TestParseFlags_PartialConfigWithOverrides(t *testing.T) {
+	path := writeConfig(t, `{"issuer": "https://file.example", "token_ttl": "30m"}`)
+	if err := parseTestFlags(t, "-config", path, "-issuer", "https://flag.example"); err != nil {
+		t.Fatalf("parseFlags failed: %v", err)
+	}
+	if issuer != "https://flag.example" {
+		t.Errorf("Expected flag to override issuer, got %q", issuer)
+	}
+	if tokenTTL != 30*time.Minute {
+		t.Errorf("Expected token TTL from file, got %v", tokenTTL)
+	}
+	if rsaBits != 2048 || listenAddr != ":8080" {
+		t.Errorf("Expected defaults for unset fields, got bits=%d addr=%q", rsaBits, listenAddr)
+	}
+}
+
+// Test malformed and invalid config files are rejected
+func TestParseFlags_BadConfig(t *testing.T) {
+	for name, body := range map[string]string{
+		"malformed":     `{"issuer": `,
+		"unknown field": `{"issuer": "x", "colour": "blue"}`,
+		"bad duration":  `{"token_ttl": "forever"}`,
+		"weak key":      `{"rsa_bits": 1024}`,
+		"empty issuer":  `{}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := writeConfig(t, body)
+			args := []string{"-config", path}
+			if name == "empty issuer" {
+				args = append(args, "-issuer", "")
+			}
+			if err := parseTestFlags(t, args...); err == nil {
+				t.Error("Expected config error")
+			}
+		})
+	}
+}
+
+// Test -addr beats $PORT, which beats the default
+func TestParseFlags_ListenAddr(t *testing.T) {
+	// Unset before the flag reset in parseTestFlags' cleanup runs
+	os.Setenv("PORT", "9999")
+	defer os.Unsetenv("PORT")
+	if err := parseTestFlags(t); err != nil || listenAddr != ":9999" {
+		t.Errorf("Expected $PORT fallback, got %q (%v)", listenAddr, err)
+	}
+	if err := parseTestFlags(t, "-addr", "127.0.0.1:7000"); err != nil || listenAddr != "127.0.0.1:7000" {
+		t.Errorf("Expected -addr to win, got %q (%v)", listenAddr, err)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+)
+
+// Allowed browser origins; "*" permits any origin
+var (
+	corsOrigins     = []string{"*"}
+	authCORSOrigins []string
+)
+
+// Adds CORS headers for allowed origins and answers preflight requests
+func withCORS(origins []string, methods string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next(w, r)
+			return
+		}
+		preflight := r.Method == "OPTIONS" && r.Header.Get("Access-Control-Request-Method") != ""
+		allowed := slices.Contains(origins, "*") || slices.Contains(origins, origin)
+		if !allowed {
+			if preflight {
+				respondError(w, 403, errors.New("Origin not allowed"))
+				return
+			}
+			next(w, r)
+			return
+		}
+
+		h := w.Header()
+		if slices.Contains(origins, "*") {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+			h.Add("Vary", "Origin")
+		}
+		h.Set("Access-Control-Expose-Headers", "ETag, X-Request-ID")
+		if preflight {
+			h.Set("Access-Control-Allow-Methods", methods)
+			h.Set("Access-Control-Allow-Headers", "Authorization, Content-Type, If-None-Match")
+			h.Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next(w, r)
+	}
+}
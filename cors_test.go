@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Test an allowed preflight gets a 204 with CORS headers
+func TestCORS_Preflight(t *testing.T) {
+	req := httptest.NewRequest("OPTIONS", "/.well-known/jwks.json", nil)
+	req.Header.Set("Origin", "https://app.example")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	w := httptest.NewRecorder()
+	withCORS([]string{"https://app.example"}, "GET", jwksHandler)(w, req)
+
+	if w.Code != 204 {
+		t.Fatalf("Expected 204, got %d", w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example" {
+		t.Errorf("Unexpected Allow-Origin %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Methods"); got != "GET" {
+		t.Errorf("Unexpected Allow-Methods %q", got)
+	}
+	if w.Header().Get("Access-Control-Allow-Headers") == "" {
+		t.Error("Expected Allow-Headers")
+	}
+}
+
+// Test the wildcard origin on a simple request
+func TestCORS_Wildcard(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	keyRing = []*KeyPair{validKey}
+	req := httptest.NewRequest("GET", "/.well-known/jwks.json", nil)
+	req.Header.Set("Origin", "https://anywhere.example")
+	w := httptest.NewRecorder()
+	withCORS([]string{"*"}, "GET", jwksHandler)(w, req)
+	if w.Code != 200 || w.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Errorf("Expected 200 with wildcard origin, got %d %q", w.Code, w.Header().Get("Access-Control-Allow-Origin"))
+	}
+}
+
+// Test a disallowed origin gets no CORS headers and a rejected preflight
+func TestCORS_DisallowedOrigin(t *testing.T) {
+	req := httptest.NewRequest("OPTIONS", "/auth", nil)
+	req.Header.Set("Origin", "https://evil.example")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	w := httptest.NewRecorder()
+	withCORS([]string{"https://app.example"}, "POST", authHandler)(w, req)
+	assertEnvelopeError(t, w, 403)
+	if w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Error("Expected no Allow-Origin for disallowed origin")
+	}
+}
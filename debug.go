@@ -0,0 +1,33 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Serve /debug endpoints; off by default so production never exposes them
+var debugEndpoints bool
+
+// Decodes, without verifying, the JWT in ?token and returns its header and claims
+func debugDecodeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		respondError(w, 405, errors.New("use GET"))
+		return
+	}
+	if !debugEndpoints {
+		respondError(w, 404, errors.New("Debug endpoints are disabled"))
+		return
+	}
+	claims := jwt.MapClaims{}
+	token, _, err := jwt.NewParser().ParseUnverified(r.URL.Query().Get("token"), claims)
+	if err != nil {
+		respondError(w, 400, fmt.Errorf("Malformed token: %w", err))
+		return
+	}
+	if err := respondJSON(w, 200, map[string]any{"header": token.Header, "claims": claims}); err != nil {
+		logResponseError(w, r, err)
+	}
+}
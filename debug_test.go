@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// GET /debug/decode for token through the router
+func debugDecode(token string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	newRouter().ServeHTTP(w, httptest.NewRequest("GET", "/debug/decode?token="+url.QueryEscape(token), nil))
+	return w
+}
+
+// Test a token's header and claims are decoded
+func TestDebugDecode_Valid(t *testing.T) {
+	debugEndpoints = true
+	defer func() { debugEndpoints = false }()
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+
+	w := debugDecode(mintToken(t, "/auth"))
+	if w.Code != 200 {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var decoded struct {
+		Header map[string]any `json:"header"`
+		Claims map[string]any `json:"claims"`
+	}
+	envelopeData(t, w.Body.Bytes(), &decoded)
+	if decoded.Header["kid"] != validKey.Kid || decoded.Header["alg"] != "RS256" || decoded.Claims["sub"] != "user123" {
+		t.Errorf("Unexpected decode: %+v", decoded)
+	}
+}
+
+// Test a malformed token is a 400
+func TestDebugDecode_Malformed(t *testing.T) {
+	debugEndpoints = true
+	defer func() { debugEndpoints = false }()
+	assertEnvelopeError(t, debugDecode("not-a-jwt"), 400)
+}
+
+// Test the endpoint is 404 unless -debug is set
+func TestDebugDecode_Disabled(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	assertEnvelopeError(t, debugDecode(mintToken(t, "/auth")), 404)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Largest JSON request body accepted by any endpoint
+const maxBodyBytes = 1 << 20
+
+var errBodyTooLarge = fmt.Errorf("request body exceeds %d bytes", maxBodyBytes)
+
+// Caps the request body at maxBodyBytes for handlers that read one
+func withBodyLimit(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+		next(w, r)
+	}
+}
+
+// Decodes a single JSON object from the request body, rejecting unknown
+// fields and oversized bodies. Errors carry a message safe to show clients.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return describeDecodeError(err)
+	}
+	if dec.More() {
+		return errors.New("request body must contain a single JSON object")
+	}
+	return nil
+}
+
+func describeDecodeError(err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var sizeErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &sizeErr):
+		return errBodyTooLarge
+	case errors.Is(err, io.EOF):
+		return errors.New("request body is empty")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return errors.New("malformed JSON: unexpected end of body")
+	case errors.As(err, &syntaxErr):
+		return fmt.Errorf("malformed JSON at offset %d", syntaxErr.Offset)
+	case errors.As(err, &typeErr):
+		return fmt.Errorf("field %q must be of type %s", typeErr.Field, typeErr.Type)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		return fmt.Errorf("unknown field %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+	}
+	return errors.New("invalid request body")
+}
+
+// Maps a decodeJSON error to a 413 or 400 problem response
+func writeDecodeError(w http.ResponseWriter, err error) {
+	p := decodeProblem(err)
+	respondError(w, p.Status, p)
+}
+
+// Problem for a decodeJSON error: 413 for oversized bodies, 400 otherwise
+func decodeProblem(err error) *Problem {
+	if errors.Is(err, errBodyTooLarge) {
+		return newProblem(413, "", "Content Too Large", err.Error())
+	}
+	return newProblem(400, "", "Bad Request", err.Error())
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func postAuth(body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	authHandler(w, httptest.NewRequest("POST", "/auth", strings.NewReader(body)))
+	return w
+}
+
+// Test truncated JSON is reported as malformed
+func TestDecodeJSON_Truncated(t *testing.T) {
+	p := assertEnvelopeError(t, postAuth(`{"username":"user123","pass`), 400)
+	if !strings.Contains(p.Detail, "malformed JSON") {
+		t.Errorf("Expected malformed JSON detail, got %q", p.Detail)
+	}
+}
+
+// Test unknown fields are rejected by name
+func TestDecodeJSON_UnknownField(t *testing.T) {
+	p := assertEnvelopeError(t, postAuth(`{"username":"user123","password":"password123","admin":true}`), 400)
+	if p.Detail != `unknown field "admin"` {
+		t.Errorf("Expected unknown field detail, got %q", p.Detail)
+	}
+}
+
+// Test wrongly typed fields name the field
+func TestDecodeJSON_WrongType(t *testing.T) {
+	p := assertEnvelopeError(t, postAuth(`{"username":42,"password":"password123"}`), 400)
+	if !strings.Contains(p.Detail, `"username"`) {
+		t.Errorf("Expected detail to name the field, got %q", p.Detail)
+	}
+}
+
+// Test trailing data after the object is rejected
+func TestDecodeJSON_TrailingData(t *testing.T) {
+	assertEnvelopeError(t, postAuth(`{"username":"user123","password":"password123"}{}`), 400)
+}
+
+// Test bodies over the limit get 413
+func TestDecodeJSON_TooLarge(t *testing.T) {
+	body := `{"username":"` + strings.Repeat("a", maxBodyBytes) + `"}`
+	assertEnvelopeError(t, postAuth(body), 413)
+}
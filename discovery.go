@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Issuer identifier and public base URL used to build absolute endpoint URLs
+var (
+	issuer  = "http://localhost:8080"
+	baseURL = ""
+	// Add the absolute JWKS URL to /auth responses as jwks_uri
+	authJWKSURI bool
+)
+
+// OpenID Connect discovery document
+type OpenIDConfiguration struct {
+	Issuer                           string   `json:"issuer"`
+	JWKSURI                          string   `json:"jwks_uri"`
+	TokenEndpoint                    string   `json:"token_endpoint"`
+	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported"`
+}
+
+// Absolute URL for a server path, based on baseURL or the issuer
+func absoluteURL(path string) string {
+	base := baseURL
+	if base == "" {
+		base = issuer
+	}
+	return strings.TrimSuffix(base, "/") + path
+}
+
+func discoveryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		respondError(w, 405, errors.New("use GET"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(OpenIDConfiguration{
+		Issuer:                           issuer,
+		JWKSURI:                          absoluteURL("/.well-known/jwks.json"),
+		TokenEndpoint:                    absoluteURL("/auth"),
+		IDTokenSigningAlgValuesSupported: []string{signingAlg},
+	})
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+)
+
+// Test the discovery document points at the JWKS and advertises RS256
+func TestDiscoveryHandler(t *testing.T) {
+	originalIssuer, originalBase := issuer, baseURL
+	issuer, baseURL = "https://issuer.example", "https://api.example/"
+	defer func() { issuer, baseURL = originalIssuer, originalBase }()
+
+	w := httptest.NewRecorder()
+	discoveryHandler(w, httptest.NewRequest("GET", "/.well-known/openid-configuration", nil))
+	if w.Code != 200 {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	var doc OpenIDConfiguration
+	json.Unmarshal(w.Body.Bytes(), &doc)
+	if doc.Issuer != "https://issuer.example" {
+		t.Errorf("Unexpected issuer %q", doc.Issuer)
+	}
+	if doc.JWKSURI != "https://api.example/.well-known/jwks.json" {
+		t.Errorf("Unexpected jwks_uri %q", doc.JWKSURI)
+	}
+	if doc.TokenEndpoint != "https://api.example/auth" {
+		t.Errorf("Unexpected token_endpoint %q", doc.TokenEndpoint)
+	}
+	if !slices.Contains(doc.IDTokenSigningAlgValuesSupported, "RS256") {
+		t.Errorf("Expected RS256 in %v", doc.IDTokenSigningAlgValuesSupported)
+	}
+}
+
+// Test discovery wrong method
+func TestDiscoveryHandler_WrongMethod(t *testing.T) {
+	w := httptest.NewRecorder()
+	discoveryHandler(w, httptest.NewRequest("POST", "/.well-known/openid-configuration", nil))
+	if w.Code != 405 {
+		t.Errorf("Expected 405, got %d", w.Code)
+	}
+}
+
+// Test -auth-jwks-uri adds the absolute JWKS URL to /auth responses, and only then
+func TestAuthHandler_JWKSURI(t *testing.T) {
+	originalIssuer, originalBase := issuer, baseURL
+	issuer, baseURL = "https://issuer.example", "https://api.example/"
+	defer func() { issuer, baseURL = originalIssuer, originalBase }()
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+
+	w := httptest.NewRecorder()
+	authHandler(w, loginRequest("/auth", "user123", "password123"))
+	if uri, ok := authResponse(t, w.Body.Bytes())["jwks_uri"]; ok {
+		t.Errorf("Expected no jwks_uri by default, got %q", uri)
+	}
+
+	authJWKSURI = true
+	defer func() { authJWKSURI = false }()
+	w = httptest.NewRecorder()
+	authHandler(w, loginRequest("/auth", "user123", "password123"))
+	uri := authResponse(t, w.Body.Bytes())["jwks_uri"]
+	if u, err := url.Parse(uri); err != nil || !u.IsAbs() || uri != "https://api.example/.well-known/jwks.json" {
+		t.Errorf("Expected the absolute JWKS URL, got %q", uri)
+	}
+}
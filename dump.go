@@ -0,0 +1,17 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// Print the JWKS and exit instead of serving
+var dumpJWKSOnly bool
+
+// Writes the currently published JWKS as indented JSON
+func dumpJWKS(out io.Writer) error {
+	now := nowFunc()
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(buildJWKS(publishedKeys(now), publishedEncKey(now)))
+}
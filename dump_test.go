@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+// Test the dump path generates keys and prints valid JWKS JSON
+func TestDumpJWKS(t *testing.T) {
+	signingAlg = "ES256"
+	original := generateKeyPairFunc
+	generateKeyPairFunc = generateECKeyPair
+	defer func() { signingAlg, generateKeyPairFunc = "RS256", original }()
+	keyRing = nil
+	if err := initKeys(); err != nil {
+		t.Fatalf("initKeys failed: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := dumpJWKS(&out); err != nil {
+		t.Fatalf("dumpJWKS failed: %v", err)
+	}
+	var jwks JWKS
+	if err := json.Unmarshal(out.Bytes(), &jwks); err != nil {
+		t.Fatalf("Invalid JWKS JSON: %v\n%s", err, out.String())
+	}
+	if len(jwks.Keys) != 1 || jwks.Keys[0].Kid != validKey.Kid || jwks.Keys[0].Alg != "ES256" {
+		t.Errorf("Expected the generated ES256 key, got %+v", jwks.Keys)
+	}
+}
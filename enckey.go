@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// JWK "use" values
+const (
+	useSig = "sig"
+	useEnc = "enc"
+)
+
+// Whether to publish an RSA-OAEP-256 encryption key alongside the signing keys
+var publishEncKey bool
+
+// Current encryption key, nil unless publishEncKey is set
+var encKey *KeyPair
+
+// Generates RSA encryption keys when the signing keys are EC; swapped out by tests
+var generateRSAEncKey = generateKeyPair
+
+// Generates a fresh RSA encryption key when publishing one is enabled.
+// Always RSA, regardless of the signing algorithm: with an RSA signing alg
+// it comes from nextKey like a signing key, under ES256 (whose pool and
+// generator yield EC keys) from generateRSAEncKey with the same retries.
+// Generation waits for a keygenSlots slot.
+func refreshEncKey(expiresAt time.Time) error {
+	if !publishEncKey {
+		return nil
+	}
+	var kp *KeyPair
+	var err error
+	if signingAlg == "ES256" {
+		kp, err = withKeygenSlot(true, func() (*KeyPair, error) {
+			return generateWithRetryUsing(context.Background(), generateRSAEncKey, expiresAt, rsaBits)
+		})
+	} else {
+		kp, err = nextKey(expiresAt, true)
+	}
+	if err == nil {
+		err = validateKeyPair(kp)
+	}
+	if err != nil {
+		return err
+	}
+	keyMu.Lock()
+	defer keyMu.Unlock()
+	encKey = kp
+	return nil
+}
+
+// Encryption key to publish at now, or nil
+func publishedEncKey(now time.Time) *KeyPair {
+	keyMu.RLock()
+	defer keyMu.RUnlock()
+	if kp := encKey; kp != nil && now.Before(kp.ExpiresAt.Add(jwksGrace)) {
+		return kp
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Test the JWKS carries one sig and one enc key when enabled
+func TestJWKSHandler_EncKey(t *testing.T) {
+	publishEncKey = true
+	defer func() { publishEncKey, encKey = false, nil }()
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	keyRing = []*KeyPair{validKey}
+	if err := refreshEncKey(validKey.ExpiresAt); err != nil {
+		t.Fatalf("Encryption key generation failed: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	jwksHandler(w, httptest.NewRequest("GET", "/.well-known/jwks.json", nil))
+	var jwks JWKS
+	json.Unmarshal(w.Body.Bytes(), &jwks)
+	if len(jwks.Keys) != 2 {
+		t.Fatalf("Expected 2 keys, got %d", len(jwks.Keys))
+	}
+	sig, enc := jwks.Keys[0], jwks.Keys[1]
+	if sig.Use != "sig" || sig.Alg != "RS256" || sig.Kid != validKey.Kid {
+		t.Errorf("Unexpected signing key %+v", sig)
+	}
+	if enc.Use != "enc" || enc.Alg != "RSA-OAEP-256" || enc.Kty != "RSA" || enc.Kid != encKey.Kid {
+		t.Errorf("Unexpected encryption key %+v", enc)
+	}
+}
+
+// Test no enc key is generated unless enabled
+func TestRefreshEncKey_Disabled(t *testing.T) {
+	if err := refreshEncKey(time.Now().Add(time.Hour)); err != nil || encKey != nil {
+		t.Errorf("Expected no encryption key, got %v (err %v)", encKey, err)
+	}
+}
+
+// Test the encryption key comes from the pool or generateKeyPairFunc like a signing key
+func TestRefreshEncKey_UsesKeySources(t *testing.T) {
+	publishEncKey = true
+	pooled, _ := generateKeyPair(time.Now(), 2048)
+	injected, _ := generateKeyPair(time.Now(), 2048)
+	keyPool = make(chan *KeyPair, 1)
+	keyPool <- pooled
+	original := generateKeyPairFunc
+	generateKeyPairFunc = func(time.Time, int) (*KeyPair, error) { return injected, nil }
+	defer func() { publishEncKey, encKey, keyPool, generateKeyPairFunc = false, nil, nil, original }()
+
+	exp := time.Now().Add(time.Hour)
+	for _, want := range []*KeyPair{pooled, injected} {
+		if err := refreshEncKey(exp); err != nil {
+			t.Fatalf("refreshEncKey failed: %v", err)
+		}
+		if encKey != want {
+			t.Errorf("Expected encryption key %s, got %s", want.Kid, encKey.Kid)
+		}
+	}
+}
+
+// Test ES256 servers still get an RSA encryption key, with retries
+func TestRefreshEncKey_ES256(t *testing.T) {
+	publishEncKey, signingAlg = true, "ES256"
+	original := generateRSAEncKey
+	calls := 0
+	generateRSAEncKey = func(expiresAt time.Time, bits int) (*KeyPair, error) {
+		if calls++; calls == 1 {
+			return nil, errors.New("transient")
+		}
+		return original(expiresAt, bits)
+	}
+	defer func() { publishEncKey, signingAlg, encKey, generateRSAEncKey = false, "RS256", nil, original }()
+
+	if err := refreshEncKey(time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("refreshEncKey failed: %v", err)
+	}
+	if encKey.PublicKey == nil || calls != 2 {
+		t.Errorf("Expected an RSA key after a retry, got %+v after %d calls", encKey, calls)
+	}
+}
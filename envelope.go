@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// Uniform JSON response body: exactly one of data and error is non-null
+type envelope struct {
+	Data  any      `json:"data"`
+	Error *Problem `json:"error"`
+}
+
+// Writes data as {"data":...,"error":null}. Data that cannot be encoded
+// becomes a 500 instead; either failure is returned for logResponseError.
+func respondJSON(w http.ResponseWriter, status int, data any) error {
+	return writeEnvelope(w, status, envelope{Data: data})
+}
+
+// Writes err as {"data":null,"error":{...}}. A *Problem keeps its title,
+// detail and code; any other error becomes the detail under the status text.
+func respondError(w http.ResponseWriter, status int, err error) {
+	p := newProblem(status, "", http.StatusText(status), err.Error())
+	var pe *Problem
+	if errors.As(err, &pe) {
+		p = newProblem(status, pe.Code, pe.Title, pe.Detail)
+	}
+	w.Header().Del("Content-Length")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	writeEnvelope(w, status, envelope{Error: p})
+}
+
+// Encodes body before writing anything, so an encoding failure can still be a 500
+func writeEnvelope(w http.ResponseWriter, status int, body envelope) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		status = 500
+		b, _ = json.Marshal(envelope{Error: newProblem(500, "encoding_failed", "Internal Server Error", "Failed to encode response")})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, werr := w.Write(append(b, '\n'))
+	return errors.Join(err, werr)
+}
+
+// Logs a response that failed to encode or write; the status has already been sent
+func logResponseError(w http.ResponseWriter, r *http.Request, err error) {
+	logger.Error("response write failed",
+		"request_id", w.Header().Get("X-Request-ID"),
+		"method", r.Method,
+		"path", r.URL.Path,
+		"error", err,
+	)
+}
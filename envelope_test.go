@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Unwrap the data of a success envelope into v
+func envelopeData(t *testing.T, body []byte, v any) {
+	t.Helper()
+	var env struct {
+		Data  json.RawMessage `json:"data"`
+		Error *Problem        `json:"error"`
+	}
+	if err := json.Unmarshal(body, &env); err != nil || env.Error != nil {
+		t.Fatalf("Expected a success envelope, got %s", body)
+	}
+	if err := json.Unmarshal(env.Data, v); err != nil {
+		t.Fatalf("Invalid data: %v", err)
+	}
+}
+
+// Unwrap the data of a successful /auth envelope
+func authResponse(t *testing.T, body []byte) map[string]string {
+	t.Helper()
+	var resp map[string]string
+	envelopeData(t, body, &resp)
+	return resp
+}
+
+// Assert a response is an error envelope with the given status
+func assertEnvelopeError(t *testing.T, w *httptest.ResponseRecorder, status int) Problem {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("Expected %d, got %d", status, w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected application/json content type, got %q", ct)
+	}
+	var env map[string]json.RawMessage
+	if err := json.Unmarshal(w.Body.Bytes(), &env); err != nil {
+		t.Fatalf("Invalid envelope: %v", err)
+	}
+	if len(env) != 2 || string(env["data"]) != "null" {
+		t.Errorf("Expected data:null alongside error, got %s", w.Body.String())
+	}
+	var p Problem
+	if err := json.Unmarshal(env["error"], &p); err != nil {
+		t.Fatalf("Invalid error object: %v", err)
+	}
+	if p.Title == "" || p.Status != status {
+		t.Errorf("Unexpected error object: %+v", p)
+	}
+	return p
+}
+
+// Test a success envelope carries data and a null error
+func TestRespondJSON_Envelope(t *testing.T) {
+	w := httptest.NewRecorder()
+	respondJSON(w, 200, map[string]string{"token": "abc"})
+	if w.Body.String() != `{"data":{"token":"abc"},"error":null}`+"\n" {
+		t.Errorf("Unexpected envelope %s", w.Body.String())
+	}
+	if w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Unexpected content type %q", w.Header().Get("Content-Type"))
+	}
+}
+
+// Test plain errors and problems both land in the error member
+func TestRespondError_Envelope(t *testing.T) {
+	w := httptest.NewRecorder()
+	respondError(w, 401, errors.New("invalid credentials"))
+	if p := assertEnvelopeError(t, w, 401); p.Title != "Unauthorized" || p.Detail != "invalid credentials" {
+		t.Errorf("Unexpected error %+v", p)
+	}
+
+	w = httptest.NewRecorder()
+	respondError(w, 504, newProblem(504, "signing_timeout", "Gateway Timeout", "Signing timed out"))
+	if p := assertEnvelopeError(t, w, 504); p.Code != "signing_timeout" || p.Detail != "Signing timed out" {
+		t.Errorf("Unexpected error %+v", p)
+	}
+}
+
+// Test /auth wraps both outcomes in the envelope
+func TestAuthHandler_Envelope(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	w := httptest.NewRecorder()
+	authHandler(w, loginRequest("/auth", "user123", "password123"))
+	if resp := authResponse(t, w.Body.Bytes()); resp["token"] == "" {
+		t.Errorf("Expected data.token, got %v", resp)
+	}
+
+	w = httptest.NewRecorder()
+	authHandler(w, loginRequest("/auth", "user123", "wrong"))
+	assertEnvelopeError(t, w, 401)
+}
+
+// Test the JWKS stays a raw key set but its errors use the envelope
+func TestJWKSHandler_Envelope(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	keyRing = []*KeyPair{validKey}
+	w := httptest.NewRecorder()
+	jwksHandler(w, httptest.NewRequest("GET", "/.well-known/jwks.json", nil))
+	var raw map[string]json.RawMessage
+	json.Unmarshal(w.Body.Bytes(), &raw)
+	if _, ok := raw["keys"]; !ok || len(raw) != 1 {
+		t.Errorf("Expected a bare JWKS, got %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	jwksHandler(w, httptest.NewRequest("POST", "/.well-known/jwks.json", nil))
+	assertEnvelopeError(t, w, 405)
+}
+
+// ResponseWriter whose body writes always fail, as on a dropped connection
+type failingWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+// Test write failures in /auth and the JWKS are logged with the request
+func TestResponseWriteError_Logged(t *testing.T) {
+	var buf bytes.Buffer
+	original := logger
+	logger = slog.New(slog.NewJSONHandler(&buf, nil))
+	defer func() { logger = original }()
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	keyRing = []*KeyPair{validKey}
+	defer func() { keyRing = nil }()
+
+	authHandler(failingWriter{httptest.NewRecorder()}, loginRequest("/auth", "user123", "password123"))
+	jwksHandler(failingWriter{httptest.NewRecorder()}, httptest.NewRequest("GET", "/.well-known/jwks.json", nil))
+	if n := strings.Count(buf.String(), "response write failed"); n != 2 {
+		t.Errorf("Expected 2 logged write failures, got %d", n)
+	}
+	for _, path := range []string{"/auth", "/.well-known/jwks.json"} {
+		if !strings.Contains(buf.String(), `"path":"`+path+`","error":"connection reset"`) {
+			t.Errorf("Expected a logged write failure for %s, got %s", path, buf.String())
+		}
+	}
+}
+
+// Test data that cannot be encoded becomes a 500 before anything is written
+func TestRespondJSON_EncodeError(t *testing.T) {
+	w := httptest.NewRecorder()
+	if err := respondJSON(w, 200, map[string]any{"bad": math.Inf(1)}); err == nil {
+		t.Error("Expected the encoding error to be returned")
+	}
+	if p := assertEnvelopeError(t, w, 500); p.Code != "encoding_failed" {
+		t.Errorf("Expected code encoding_failed, got %q", p.Code)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Responses smaller than this are sent uncompressed; gzip would only add overhead
+const gzipMinSize = 1024
+
+// Compresses responses for clients that accept gzip, once the body reaches gzipMinSize
+func withGzip(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsGzip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		gw := &gzipResponseWriter{ResponseWriter: w}
+		defer gw.close()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// Reports whether Accept-Encoding lists gzip without q=0
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		q := 1.0
+		if name, value, ok := strings.Cut(params, "="); ok && strings.TrimSpace(name) == "q" {
+			q, _ = strconv.ParseFloat(strings.TrimSpace(value), 64)
+		}
+		return q > 0
+	}
+	return false
+}
+
+// Buffers the start of a response until it is big enough to be worth compressing
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	status int
+	buf    []byte
+	gz     *gzip.Writer
+	// Compression was ruled out; writes go straight to ResponseWriter
+	plain bool
+}
+
+func (g *gzipResponseWriter) WriteHeader(status int) {
+	if g.status == 0 {
+		g.status = status
+	}
+}
+
+func (g *gzipResponseWriter) Write(p []byte) (int, error) {
+	if g.status == 0 {
+		g.status = http.StatusOK
+	}
+	switch {
+	case g.gz != nil:
+		return g.gz.Write(p)
+	case g.plain:
+		return g.ResponseWriter.Write(p)
+	}
+	g.buf = append(g.buf, p...)
+	if len(g.buf) >= gzipMinSize {
+		if err := g.start(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Commits to gzip unless the handler already encoded the body itself
+func (g *gzipResponseWriter) start() error {
+	h := g.Header()
+	if h.Get("Content-Encoding") != "" {
+		return g.flushPlain()
+	}
+	h.Set("Content-Encoding", "gzip")
+	h.Del("Content-Length")
+	g.ResponseWriter.WriteHeader(g.status)
+	g.gz = gzip.NewWriter(g.ResponseWriter)
+	_, err := g.gz.Write(g.buf)
+	g.buf = nil
+	return err
+}
+
+func (g *gzipResponseWriter) flushPlain() error {
+	g.plain = true
+	if g.status != 0 {
+		g.ResponseWriter.WriteHeader(g.status)
+	}
+	_, err := g.ResponseWriter.Write(g.buf)
+	g.buf = nil
+	return err
+}
+
+func (g *gzipResponseWriter) close() {
+	if g.gz != nil {
+		g.gz.Close()
+		return
+	}
+	if !g.plain {
+		g.flushPlain()
+	}
+}
+
+func (g *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return g.ResponseWriter
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Serve target through the full server handler with the given Accept-Encoding
+func serveWithEncoding(target, encoding string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", target, nil)
+	if encoding != "" {
+		req.Header.Set("Accept-Encoding", encoding)
+	}
+	w := httptest.NewRecorder()
+	newServer(":0").Handler.ServeHTTP(w, req)
+	return w
+}
+
+// Test a large JWKS is gzipped when the client accepts it
+func TestGzip_Compressed(t *testing.T) {
+	validKey = nil
+	keyRing = keyRingOf(t, 20)
+	defer func() { keyRing = nil }()
+
+	w := serveWithEncoding("/.well-known/jwks.json", "br, gzip")
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected gzip encoding, got %q", w.Header().Get("Content-Encoding"))
+	}
+	if !strings.Contains(strings.Join(w.Header().Values("Vary"), ","), "Accept-Encoding") {
+		t.Errorf("Expected Vary: Accept-Encoding, got %v", w.Header().Values("Vary"))
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(zr)
+	var jwks JWKS
+	if err := json.Unmarshal(body, &jwks); err != nil || len(jwks.Keys) != 20 {
+		t.Errorf("Expected 20 keys after decompressing, got %d (%v)", len(jwks.Keys), err)
+	}
+}
+
+// Test responses stay uncompressed without gzip in Accept-Encoding
+func TestGzip_NotAccepted(t *testing.T) {
+	validKey = nil
+	keyRing = keyRingOf(t, 20)
+	defer func() { keyRing = nil }()
+
+	for _, encoding := range []string{"", "identity", "gzip;q=0"} {
+		w := serveWithEncoding("/.well-known/jwks.json", encoding)
+		if ce := w.Header().Get("Content-Encoding"); ce != "" {
+			t.Errorf("%q: expected no encoding, got %q", encoding, ce)
+		}
+		var jwks JWKS
+		if err := json.Unmarshal(w.Body.Bytes(), &jwks); err != nil || len(jwks.Keys) != 20 {
+			t.Errorf("%q: expected a plain JWKS, got %v", encoding, err)
+		}
+	}
+}
+
+// Test bodies under the threshold are not compressed
+func TestGzip_SmallBody(t *testing.T) {
+	w := serveWithEncoding("/version", "gzip")
+	if w.Code != 200 || w.Header().Get("Content-Encoding") != "" {
+		t.Errorf("Expected an uncompressed 200, got %d %q", w.Code, w.Header().Get("Content-Encoding"))
+	}
+	if !json.Valid(w.Body.Bytes()) {
+		t.Errorf("Expected plain JSON, got %q", w.Body.String())
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// RFC 7662 introspection response; inactive tokens carry no other fields
+type IntrospectionResponse struct {
+	Active bool   `json:"active"`
+	Sub    string `json:"sub,omitempty"`
+	Exp    int64  `json:"exp,omitempty"`
+}
+
+// Clock skew tolerated on exp and nbf when introspecting
+var introspectLeeway = 60 * time.Second
+
+// Verifies raw against the key ring key named by its kid. Only that key's own
+// alg is accepted, so a token cannot pick e.g. HS256 and use the public key as
+// an HMAC secret.
+func parseWithKeyRing(raw string, claims jwt.Claims, opts ...jwt.ParserOption) (*jwt.Token, error) {
+	unverified, _, err := jwt.NewParser().ParseUnverified(raw, jwt.MapClaims{})
+	if err != nil {
+		return nil, err
+	}
+	kid, _ := unverified.Header["kid"].(string)
+	kp, ok := findKeyByKid(kid)
+	if !ok {
+		return nil, errors.New("unknown kid")
+	}
+	opts = append(opts, jwt.WithValidMethods([]string{kp.signingMethod().Alg()}), jwt.WithTimeFunc(nowFunc))
+	return jwt.ParseWithClaims(raw, claims, func(*jwt.Token) (any, error) {
+		return kp.verificationKey(), nil
+	}, opts...)
+}
+
+func introspectHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		respondError(w, 405, errors.New("use POST"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	// Parse errors are deliberately not surfaced; any failure is simply inactive
+	resp := IntrospectionResponse{}
+	claims := jwt.MapClaims{}
+	token, err := parseWithKeyRing(r.PostFormValue("token"), claims, jwt.WithLeeway(introspectLeeway))
+	if jti, _ := claims["jti"].(string); err == nil && token.Valid && !revoked.isRevoked(jti) {
+		resp.Active = true
+		resp.Sub, _ = claims.GetSubject()
+		if exp, _ := claims.GetExpirationTime(); exp != nil {
+			resp.Exp = exp.Unix()
+		}
+	}
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Mint a token through authHandler and return it
+func mintToken(t *testing.T, target string) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	if strings.Contains(target, "expired=") {
+		authHandler(w, httptest.NewRequest("POST", target, nil))
+	} else {
+		authHandler(w, loginRequest(target, "user123", "password123"))
+	}
+	resp := authResponse(t, w.Body.Bytes())
+	if resp["token"] == "" {
+		t.Fatalf("No token minted: %d %s", w.Code, w.Body.String())
+	}
+	return resp["token"]
+}
+
+// POST a token to the introspection endpoint
+func introspect(t *testing.T, token string) IntrospectionResponse {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/introspect", strings.NewReader(url.Values{"token": {token}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	introspectHandler(w, req)
+	if w.Code != 200 {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	var resp IntrospectionResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return resp
+}
+
+// Test a freshly minted token is active
+func TestIntrospect_ValidToken(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	keyRing = []*KeyPair{validKey}
+	resp := introspect(t, mintToken(t, "/auth"))
+	if !resp.Active || resp.Sub != "user123" || resp.Exp == 0 {
+		t.Errorf("Expected active token, got %+v", resp)
+	}
+}
+
+// Test a token signed by the expired key is inactive
+func TestIntrospect_ExpiredKeyToken(t *testing.T) {
+	enableExpiredEndpoint = true
+	defer func() { enableExpiredEndpoint = false }()
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	expiredKey, _ = generateKeyPair(time.Now().Add(-time.Hour), 2048)
+	keyRing = []*KeyPair{validKey, expiredKey}
+	if resp := introspect(t, mintToken(t, "/auth?expired=true")); resp.Active {
+		t.Errorf("Expected inactive token, got %+v", resp)
+	}
+}
+
+// Test garbage input is inactive without leaking details
+func TestIntrospect_GarbageToken(t *testing.T) {
+	req := httptest.NewRequest("POST", "/introspect", strings.NewReader("token=not.a.jwt"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	introspectHandler(w, req)
+	if body := strings.TrimSpace(w.Body.String()); body != `{"active":false}` {
+		t.Errorf("Expected bare inactive response, got %s", body)
+	}
+}
+
+// Test tokens whose alg differs from their key's are inactive
+func TestIntrospect_AlgConfusion(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	keyRing = []*KeyPair{validKey}
+	claims := jwt.MapClaims{"sub": "mallory", "exp": time.Now().Add(time.Hour).Unix()}
+
+	// HS256 keyed with the public modulus, which anyone can read from the JWKS
+	hs := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	hs.Header["kid"] = validKey.Kid
+	forged, _ := hs.SignedString(validKey.PublicKey.N.Bytes())
+	// A genuine signature by the right key, but with PS256 instead of its RS256
+	ps := jwt.NewWithClaims(jwt.SigningMethodPS256, claims)
+	ps.Header["kid"] = validKey.Kid
+	swapped, _ := ps.SignedString(validKey.PrivateKey)
+
+	for name, token := range map[string]string{"HS256": forged, "PS256": swapped} {
+		if resp := introspect(t, token); resp.Active {
+			t.Errorf("%s: expected inactive token, got %+v", name, resp)
+		}
+	}
+}
+
+// Test leeway accepts a token just past exp only when large enough
+func TestIntrospect_Leeway(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	keyRing = []*KeyPair{validKey}
+	token, err := issueToken(context.Background(), validKey, "user123", nil, time.Now().Add(-30*time.Second).Unix())
+	if err != nil {
+		t.Fatalf("issueToken failed: %v", err)
+	}
+	defer func() { introspectLeeway = 60 * time.Second }()
+
+	introspectLeeway = 60 * time.Second
+	if resp := introspect(t, token); !resp.Active {
+		t.Error("Expected a token 30s past exp to be active with 60s leeway")
+	}
+	introspectLeeway = 0
+	if resp := introspect(t, token); resp.Active {
+		t.Error("Expected a token 30s past exp to be inactive with no leeway")
+	}
+}
+
+// Test ?future=true mints a not-yet-valid token that introspects inactive until its nbf
+func TestIntrospect_FutureToken(t *testing.T) {
+	enableExpiredEndpoint = true
+	defer func() { enableExpiredEndpoint = false }()
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	keyRing = []*KeyPair{validKey}
+
+	token := mintToken(t, "/auth?future=true")
+	nbf, _ := unverifiedClaims(t, token).GetNotBefore()
+	if until := time.Until(nbf.Time); until < futureNBFOffset-time.Minute {
+		t.Fatalf("Expected nbf about %v ahead, got %v", futureNBFOffset, until)
+	}
+	if resp := introspect(t, token); resp.Active {
+		t.Errorf("Expected an inactive not-yet-valid token, got %+v", resp)
+	}
+
+	freezeClock(t, nbf.Time.Add(time.Second))
+	if resp := introspect(t, token); !resp.Active {
+		t.Errorf("Expected the token active once nbf has passed, got %+v", resp)
+	}
+}
+
+// Test ?future is gated like ?expired and validated as a boolean
+func TestAuthHandler_FutureParam(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	w := httptest.NewRecorder()
+	authHandler(w, loginRequest("/auth?future=true", "user123", "password123"))
+	if p := assertEnvelopeError(t, w, 404); !strings.Contains(p.Detail, "?future=true is disabled") {
+		t.Errorf("Expected the detail to name ?future, got %q", p.Detail)
+	}
+
+	enableExpiredEndpoint = true
+	defer func() { enableExpiredEndpoint = false }()
+	setupTenants(t)
+	w = httptest.NewRecorder()
+	newRouter().ServeHTTP(w, loginRequest("/tenants/acme/auth?future=true", "user123", "password123"))
+	if p := assertEnvelopeError(t, w, 404); !strings.Contains(p.Detail, "?future=true is not available for tenants") {
+		t.Errorf("Expected the detail to name ?future for tenants, got %q", p.Detail)
+	}
+	for _, target := range []string{"/auth?future=soon", "/auth?future=true&expired=true"} {
+		w := httptest.NewRecorder()
+		authHandler(w, loginRequest(target, "user123", "password123"))
+		assertEnvelopeError(t, w, 400)
+	}
+}
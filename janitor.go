@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// How often expired keys are pruned from the store between rotations (0 disables)
+var pruneInterval = 10 * time.Minute
+
+// Drops keys past expiry plus grace, logging how many went
+func pruneKeys(now time.Time) {
+	removed, err := keyStore.Prune(now)
+	if err != nil {
+		logger.Error("key prune failed", "error", err)
+		return
+	}
+	if removed > 0 {
+		logger.Info("pruned expired keys", "removed", removed)
+	}
+}
+
+// Prunes the store every interval until ctx is cancelled
+func pruneLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pruneKeys(nowFunc())
+		}
+	}
+}
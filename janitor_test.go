@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test a prune cycle removes an expired key, logs it and stops on cancel
+func TestPruneLoop(t *testing.T) {
+	var buf bytes.Buffer
+	original := logger
+	logger = slog.New(slog.NewJSONHandler(&buf, nil))
+	defer func() { logger = original }()
+	now := time.Now()
+	live, _ := generateKeyPair(now.Add(time.Hour), 2048)
+	expiring, _ := generateKeyPair(now.Add(30*time.Second), 2048)
+	validKey = live
+	setKeyRing([]*KeyPair{expiring, live})
+
+	// Only the injected clock, a minute ahead, sees the key as expired
+	nowFunc = func() time.Time { return now.Add(time.Minute) }
+	defer func() { nowFunc = time.Now }()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		pruneLoop(ctx, time.Millisecond)
+		close(done)
+	}()
+	deadline := time.After(5 * time.Second)
+	for all, _ := keyStore.All(); len(all) != 1; all, _ = keyStore.All() {
+		select {
+		case <-deadline:
+			t.Fatal("Expired key was not pruned")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	cancel()
+	<-done
+
+	all, _ := keyStore.All()
+	if len(all) != 1 || all[0] != live {
+		t.Errorf("Expected only the live key, got %v", all)
+	}
+	if !strings.Contains(buf.String(), `"removed":1`) {
+		t.Errorf("Expected a log line with the removed count, got %q", buf.String())
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Maximum random adjustment to a new key's lifetime, as a percentage (0 disables)
+var expiryJitter float64
+
+// Jitter source; tests replace it with a seeded generator
+var (
+	jitterMu   sync.Mutex
+	jitterRand = rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+)
+
+// Spreads expiresAt by up to ±expiryJitter% of the time remaining until it,
+// so servers started together do not all rotate at the same instant
+func jitterExpiry(expiresAt time.Time) time.Time {
+	lifetime := expiresAt.Sub(nowFunc())
+	if expiryJitter <= 0 || lifetime <= 0 {
+		return expiresAt
+	}
+	jitterMu.Lock()
+	f := jitterRand.Float64()*2 - 1
+	jitterMu.Unlock()
+	return expiresAt.Add(time.Duration(f * expiryJitter / 100 * float64(lifetime)))
+}
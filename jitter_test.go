@@ -0,0 +1,40 @@
+package main
+
+import (
+	"math/rand/v2"
+	"testing"
+	"time"
+)
+
+// Test jittered expiries stay within ±X% and are spread out
+func TestGenerateKeyPair_ExpiryJitter(t *testing.T) {
+	expiryJitter = 10
+	original := jitterRand
+	jitterRand = rand.New(rand.NewPCG(1, 2))
+	defer func() { expiryJitter, jitterRand = 0, original }()
+
+	base := time.Now().Add(10 * time.Hour)
+	lo, hi := base.Add(-time.Hour-time.Second), base.Add(time.Hour)
+	seen := map[time.Time]bool{}
+	for range 100 {
+		kp, err := generateECKeyPair(base, 0)
+		if err != nil {
+			t.Fatalf("Key generation failed: %v", err)
+		}
+		if kp.ExpiresAt.Before(lo) || kp.ExpiresAt.After(hi) {
+			t.Fatalf("Expiry %v outside [%v, %v]", kp.ExpiresAt, lo, hi)
+		}
+		seen[kp.ExpiresAt] = true
+	}
+	if len(seen) < 2 {
+		t.Error("Expected jittered expiries to differ")
+	}
+}
+
+// Test no jitter leaves the expiry untouched
+func TestJitterExpiry_Disabled(t *testing.T) {
+	base := time.Now().Add(time.Hour)
+	if got := jitterExpiry(base); !got.Equal(base) {
+		t.Errorf("Expected %v, got %v", base, got)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+// Encrypt /auth tokens as nested JWS-in-JWE to the public key in this PEM file
+var (
+	jweKeyFile string
+	jweAlg     = string(jose.RSA_OAEP_256)
+	jweEnc     = string(jose.A256GCM)
+)
+
+// Built by initJWE when -jwe-key is set; nil leaves tokens as plain JWS
+var jweEncrypter jose.Encrypter
+
+// Key management and content encryption algorithms accepted for -jwe-alg and -jwe-enc
+var (
+	jweAlgs = []jose.KeyAlgorithm{jose.RSA_OAEP_256, jose.RSA_OAEP, jose.ECDH_ES, jose.ECDH_ES_A256KW}
+	jweEncs = []jose.ContentEncryption{jose.A128GCM, jose.A256GCM, jose.A128CBC_HS256, jose.A256CBC_HS512}
+)
+
+// Parses an SPKI ("PUBLIC KEY") PEM block
+func parsePublicKeyPEM(data []byte) (any, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	if block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("unsupported PEM block type %q, want PUBLIC KEY", block.Type)
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+// Loads -jwe-key and builds the encrypter; a no-op when it is unset
+func initJWE() error {
+	jweEncrypter = nil
+	if jweKeyFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(jweKeyFile)
+	if err != nil {
+		return err
+	}
+	pub, err := parsePublicKeyPEM(data)
+	if err != nil {
+		return fmt.Errorf("load jwe key %s: %w", jweKeyFile, err)
+	}
+	opts := (&jose.EncrypterOptions{}).WithType("JWT").WithContentType("JWT")
+	enc, err := jose.NewEncrypter(jose.ContentEncryption(jweEnc), jose.Recipient{Algorithm: jose.KeyAlgorithm(jweAlg), Key: pub}, opts)
+	if err != nil {
+		return fmt.Errorf("jwe key %s with %s/%s: %w", jweKeyFile, jweAlg, jweEnc, err)
+	}
+	jweEncrypter = enc
+	return nil
+}
+
+// Wraps a signed token in a JWE when encryption is enabled
+func encryptToken(token string) (string, error) {
+	if jweEncrypter == nil {
+		return token, nil
+	}
+	obj, err := jweEncrypter.Encrypt([]byte(token))
+	if err != nil {
+		return "", err
+	}
+	return obj.CompactSerialize()
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Write pub as an SPKI PEM file and return its path
+func writePublicKey(t *testing.T, pub any) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwe.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// Test /auth returns a JWE that decrypts to a verifiable signed JWT
+func TestAuthHandler_JWE(t *testing.T) {
+	recipient, _ := generateKeyPair(time.Now().Add(time.Hour), 2048)
+	if err := parseTestFlags(t, "-jwe-key", writePublicKey(t, recipient.PublicKey)); err != nil {
+		t.Fatal(err)
+	}
+	if err := initJWE(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { jweEncrypter = nil }()
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+
+	w := httptest.NewRecorder()
+	authHandler(w, loginRequest("/auth", "user123", "password123"))
+	resp := authResponse(t, w.Body.Bytes())
+	jwe, err := jose.ParseEncrypted(resp["token"], []jose.KeyAlgorithm{jose.RSA_OAEP_256}, []jose.ContentEncryption{jose.A256GCM})
+	if err != nil {
+		t.Fatalf("Expected a JWE token: %v", err)
+	}
+	if jwe.Header.ExtraHeaders[jose.HeaderContentType] != "JWT" {
+		t.Errorf("Expected cty JWT, got %v", jwe.Header.ExtraHeaders)
+	}
+	inner, err := jwe.Decrypt(recipient.PrivateKey)
+	if err != nil {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+
+	token, err := jwt.Parse(string(inner), func(*jwt.Token) (any, error) { return validKey.PublicKey, nil })
+	if err != nil || !token.Valid {
+		t.Fatalf("Inner JWT did not verify: %v", err)
+	}
+	if sub, _ := token.Claims.GetSubject(); sub != "user123" || token.Header["kid"] != validKey.Kid {
+		t.Errorf("Unexpected inner token: sub %q kid %v", sub, token.Header["kid"])
+	}
+}
+
+// Test a key that does not fit the algorithm fails at startup
+func TestInitJWE_KeyAlgMismatch(t *testing.T) {
+	ec, _ := generateECKeyPair(time.Now().Add(time.Hour), 0)
+	if err := parseTestFlags(t, "-jwe-key", writePublicKey(t, &ec.ECKey.PublicKey)); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { jweEncrypter = nil }()
+	if err := initJWE(); err == nil {
+		t.Error("Expected an EC key to be rejected for RSA-OAEP-256")
+	}
+}
+
+// Test unsupported algorithms are rejected
+func TestParseFlags_InvalidJWEAlgs(t *testing.T) {
+	if err := parseTestFlags(t, "-jwe-alg", "dir"); err == nil {
+		t.Error("Expected -jwe-alg dir to be rejected")
+	}
+	if err := parseTestFlags(t, "-jwe-enc", "A192GCM"); err == nil {
+		t.Error("Expected -jwe-enc A192GCM to be rejected")
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Canonical JWK member order: the RFC 7638 thumbprint members first
+// (kty, then the key-type members), led by kid, use and alg, then the
+// optional members. Empty members are omitted.
+//
+//	kty, kid, use, alg, n, e, crv, x, y, key_ops, x5c, x5t#S256
+//
+// MarshalJSON writes this order explicitly so it survives changes to the
+// struct layout; golden tests rely on it.
+func (k JWK) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(256 + len(k.N))
+	buf.WriteByte('{')
+	writeMember(&buf, "kty", k.Kty)
+	for _, m := range [...]struct{ name, value string }{
+		{"kid", k.Kid}, {"use", k.Use}, {"alg", k.Alg},
+	} {
+		buf.WriteByte(',')
+		writeMember(&buf, m.name, m.value)
+	}
+	for _, m := range [...]struct{ name, value string }{
+		{"n", k.N}, {"e", k.E}, {"crv", k.Crv}, {"x", k.X}, {"y", k.Y},
+	} {
+		if m.value != "" {
+			buf.WriteByte(',')
+			writeMember(&buf, m.name, m.value)
+		}
+	}
+	for _, m := range [...]struct {
+		name   string
+		values []string
+	}{
+		{"key_ops", k.KeyOps}, {"x5c", k.X5C},
+	} {
+		if len(m.values) > 0 {
+			buf.WriteString(`,"` + m.name + `":[`)
+			for i, v := range m.values {
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+				writeString(&buf, v)
+			}
+			buf.WriteByte(']')
+		}
+	}
+	if k.X5TS256 != "" {
+		buf.WriteByte(',')
+		writeMember(&buf, "x5t#S256", k.X5TS256)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func writeMember(buf *bytes.Buffer, name, value string) {
+	buf.WriteByte('"')
+	buf.WriteString(name)
+	buf.WriteString(`":`)
+	writeString(buf, value)
+}
+
+// Writes s as a JSON string. Member values are almost always base64url,
+// UUIDs or algorithm names, which need no escaping; anything else goes
+// through encoding/json so the escaping matches it exactly.
+func writeString(buf *bytes.Buffer, s string) {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < 0x20 || c >= 0x7f || c == '"' || c == '\\' || c == '<' || c == '>' || c == '&' {
+			b, _ := json.Marshal(s)
+			buf.Write(b)
+			return
+		}
+	}
+	buf.WriteByte('"')
+	buf.WriteString(s)
+	buf.WriteByte('"')
+}
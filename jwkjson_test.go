@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// Test JWKs marshal byte-for-byte to the canonical member order
+func TestJWK_MarshalGolden(t *testing.T) {
+	for _, tc := range []struct {
+		jwk  JWK
+		want string
+	}{
+		{
+			JWK{Kty: "RSA", Kid: "k1", Use: "sig", Alg: "RS256", N: "sXch", E: "AQAB", KeyOps: []string{"verify"}, X5C: []string{"MIIB", "MIIC"}, X5TS256: "q1w2"},
+			`{"kty":"RSA","kid":"k1","use":"sig","alg":"RS256","n":"sXch","e":"AQAB","key_ops":["verify"],"x5c":["MIIB","MIIC"],"x5t#S256":"q1w2"}`,
+		},
+		{
+			JWK{Kty: "EC", Kid: "k2", Use: "sig", Alg: "ES256", Crv: "P-256", X: "f83O", Y: "x_FE"},
+			`{"kty":"EC","kid":"k2","use":"sig","alg":"ES256","crv":"P-256","x":"f83O","y":"x_FE"}`,
+		},
+		{
+			JWK{Kty: "RSA", Kid: `a<b>&"c"`, Use: "enc", Alg: "RSA-OAEP-256", N: "sXch", E: "AQAB"},
+			`{"kty":"RSA","kid":"a\u003cb\u003e\u0026\"c\"","use":"enc","alg":"RSA-OAEP-256","n":"sXch","e":"AQAB"}`,
+		},
+	} {
+		got, err := json.Marshal(tc.jwk)
+		if err != nil {
+			t.Fatalf("Marshal failed: %v", err)
+		}
+		if string(got) != tc.want {
+			t.Errorf("Unexpected JSON\n got: %s\nwant: %s", got, tc.want)
+		}
+	}
+}
+
+// Test a real key marshals identically every time and round-trips
+func TestJWK_MarshalStable(t *testing.T) {
+	kp, _ := generateKeyPair(time.Now().Add(time.Hour), 2048)
+	first, _ := json.Marshal(kp.toJWK(useSig))
+	for range 100 {
+		if again, _ := json.Marshal(kp.toJWK(useSig)); string(again) != string(first) {
+			t.Fatalf("Output changed between runs:\n%s\n%s", first, again)
+		}
+	}
+	var back JWK
+	if err := json.Unmarshal(first, &back); err != nil || back.N != kp.toJWK(useSig).N || back.Kid != kp.Kid {
+		t.Errorf("Expected a round trip, got %+v (%v)", back, err)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"iter"
+)
+
+// Yields the JWK for each published signing key, then the encryption key if any
+func jwkSeq(published []*KeyPair, enc *KeyPair) iter.Seq[JWK] {
+	return func(yield func(JWK) bool) {
+		for _, kp := range published {
+			if !yield(kp.toJWK(useSig)) {
+				return
+			}
+		}
+		if enc != nil {
+			yield(enc.toJWK(useEnc))
+		}
+	}
+}
+
+// Writes a JWKS document one key at a time instead of building a []JWK first.
+// The output is byte-identical to json.Encoder encoding the equivalent JWKS.
+func streamJWKS(w io.Writer, keys iter.Seq[JWK]) error {
+	if _, err := io.WriteString(w, `{"keys":[`); err != nil {
+		return err
+	}
+	sep := ""
+	for jwk := range keys {
+		b, err := json.Marshal(jwk)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, sep); err != nil {
+			return err
+		}
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+		sep = ","
+	}
+	_, err := io.WriteString(w, "]}\n")
+	return err
+}
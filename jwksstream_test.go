@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Copies of one key under distinct kids, so large rings are cheap to build
+func keyRingOf(t *testing.T, n int) []*KeyPair {
+	t.Helper()
+	base, err := generateKeyPair(time.Now().Add(time.Hour), 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ring := make([]*KeyPair, n)
+	for i := range ring {
+		kp := *base
+		kp.Kid = fmt.Sprintf("kid-%04d", i)
+		ring[i] = &kp
+	}
+	return ring
+}
+
+// Test streamed output matches encoding the whole JWKS at once
+func TestStreamJWKS_MatchesEncoder(t *testing.T) {
+	ring := keyRingOf(t, 2)
+	for _, tc := range []struct {
+		published []*KeyPair
+		enc       *KeyPair
+	}{{nil, nil}, {ring[:1], nil}, {ring, ring[0]}} {
+		var want, got bytes.Buffer
+		json.NewEncoder(&want).Encode(buildJWKS(tc.published, tc.enc))
+		if err := streamJWKS(&got, jwkSeq(tc.published, tc.enc)); err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != want.String() {
+			t.Errorf("Streamed JWKS differs:\n got %s\nwant %s", got.String(), want.String())
+		}
+	}
+}
+
+// Test a 1000-key ring streams as valid JSON with a fixed allocation cost per key
+func TestJWKSHandler_LargeKeySet(t *testing.T) {
+	validKey = nil
+	keyRing = keyRingOf(t, 1000)
+	defer func() { keyRing = nil }()
+
+	w := httptest.NewRecorder()
+	jwksHandler(w, httptest.NewRequest("GET", "/.well-known/jwks.json", nil))
+	var jwks JWKS
+	if err := json.Unmarshal(w.Body.Bytes(), &jwks); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if len(jwks.Keys) != 1000 {
+		t.Fatalf("Expected 1000 keys, got %d", len(jwks.Keys))
+	}
+
+	allocs := testing.AllocsPerRun(5, func() {
+		streamJWKS(io.Discard, jwkSeq(keyRing, nil))
+	})
+	if allocs > 1000*20 {
+		t.Errorf("Expected at most 20 allocations per key, got %.0f in total", allocs)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type claimsKey struct{}
+
+// Rejects requests without a valid bearer JWT signed by a key in the ring,
+// passing the verified claims on through the request context
+func requireJWT(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		raw, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || raw == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="jwks-server"`)
+			respondError(w, 401, errors.New("Missing bearer token"))
+			return
+		}
+		claims := jwt.MapClaims{}
+		token, err := parseWithKeyRing(raw, claims)
+		if jti, _ := claims["jti"].(string); err != nil || !token.Valid || revoked.isRevoked(jti) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="jwks-server", error="invalid_token"`)
+			respondError(w, 401, errors.New("Invalid or expired token"))
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), claimsKey{}, claims)))
+	}
+}
+
+// Verified claims stored by requireJWT
+func claimsFromContext(ctx context.Context) (jwt.MapClaims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(jwt.MapClaims)
+	return claims, ok
+}
+
+// Sample protected resource echoing the caller's subject
+func meHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		respondError(w, 405, errors.New("use GET"))
+		return
+	}
+	claims, _ := claimsFromContext(r.Context())
+	sub, _ := claims.GetSubject()
+	if err := respondJSON(w, 200, map[string]string{"sub": sub}); err != nil {
+		logResponseError(w, r, err)
+	}
+}
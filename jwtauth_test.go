@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// GET /me through the router with an optional bearer token
+func meRequest(token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/me", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	newRouter().ServeHTTP(w, req)
+	return w
+}
+
+// Test a valid token reaches the handler with its claims
+func TestRequireJWT_Valid(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	keyRing = []*KeyPair{validKey}
+
+	w := meRequest(mintToken(t, "/auth"))
+	if w.Code != 200 {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp map[string]string
+	envelopeData(t, w.Body.Bytes(), &resp)
+	if resp["sub"] != "user123" {
+		t.Errorf("Expected sub user123, got %v", resp)
+	}
+}
+
+// Test an expired token is rejected
+func TestRequireJWT_Expired(t *testing.T) {
+	enableExpiredEndpoint = true
+	defer func() { enableExpiredEndpoint = false }()
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	expiredKey, _ = generateKeyPair(time.Now().Add(-time.Hour), 2048)
+	keyRing = []*KeyPair{validKey, expiredKey}
+
+	w := meRequest(mintToken(t, "/auth?expired=true"))
+	assertEnvelopeError(t, w, 401)
+	if w.Header().Get("WWW-Authenticate") == "" {
+		t.Error("Expected WWW-Authenticate header")
+	}
+}
+
+// Test a missing Authorization header is rejected
+func TestRequireJWT_MissingHeader(t *testing.T) {
+	assertEnvelopeError(t, meRequest(""), 401)
+}
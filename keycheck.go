@@ -0,0 +1,48 @@
+package main
+
+import (
+	"crypto/elliptic"
+	"errors"
+	"fmt"
+)
+
+// Lowest value -min-rsa-bits accepts; the flag can only raise the floor
+const rsaBitsFloor = 2048
+
+// Smallest RSA modulus accepted for any key we publish or sign with
+var minRSABits = rsaBitsFloor
+
+// Rejects keys that are unsafe to publish or sign with, whatever produced them
+func validateKeyPair(kp *KeyPair) error {
+	if kp == nil {
+		return errors.New("invalid key: missing key pair")
+	}
+	if kp.ECKey != nil {
+		if kp.ECKey.Curve != elliptic.P256() {
+			return fmt.Errorf("invalid key %s: curve %s is not P-256", kp.Kid, kp.ECKey.Curve.Params().Name)
+		}
+		return nil
+	}
+	pub := kp.PublicKey
+	if pub == nil || pub.N == nil {
+		return fmt.Errorf("invalid key %s: missing RSA public key", kp.Kid)
+	}
+	if bits := pub.N.BitLen(); bits < minRSABits {
+		return fmt.Errorf("invalid key %s: %d-bit modulus is below the minimum of %d bits", kp.Kid, bits, minRSABits)
+	}
+	if pub.E <= 1 || pub.E%2 == 0 {
+		return fmt.Errorf("invalid key %s: public exponent %d must be odd and greater than 1", kp.Kid, pub.E)
+	}
+	if priv := kp.PrivateKey; priv != nil {
+		if len(priv.Primes) < 2 {
+			return fmt.Errorf("invalid key %s: missing RSA primes", kp.Kid)
+		}
+		if priv.N.Cmp(pub.N) != 0 || priv.E != pub.E {
+			return fmt.Errorf("invalid key %s: private and public keys do not match", kp.Kid)
+		}
+		if err := priv.Validate(); err != nil {
+			return fmt.Errorf("invalid key %s: %w", kp.Kid, err)
+		}
+	}
+	return nil
+}
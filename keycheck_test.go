@@ -0,0 +1,78 @@
+package main
+
+import (
+	"crypto/rsa"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// Build a public-only key pair with the given modulus size and exponent
+func weakKeyPair(bits, e int) *KeyPair {
+	n := new(big.Int).Lsh(big.NewInt(1), uint(bits-1))
+	n.Add(n, big.NewInt(1))
+	return &KeyPair{Kid: "weak", PublicKey: &rsa.PublicKey{N: n, E: e}, ExpiresAt: time.Now().Add(time.Hour)}
+}
+
+// Test initKeys refuses an injected 512-bit key
+func TestInitKeys_RejectsWeakKey(t *testing.T) {
+	original := generateKeyPairFunc
+	generateKeyPairFunc = func(time.Time, int) (*KeyPair, error) { return weakKeyPair(512, 65537), nil }
+	defer func() { generateKeyPairFunc = original }()
+
+	if err := initKeys(); err == nil {
+		t.Error("Expected initKeys to reject a 512-bit key")
+	}
+}
+
+// Test rotation never publishes a weak key
+func TestRotateKeys_RejectsWeakKey(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	setKeyRing([]*KeyPair{validKey})
+	original := generateKeyPairFunc
+	generateKeyPairFunc = func(time.Time, int) (*KeyPair, error) { return weakKeyPair(512, 65537), nil }
+	defer func() { generateKeyPairFunc = original }()
+
+	if _, err := rotateKeys(true); err == nil {
+		t.Fatal("Expected rotation to reject a 512-bit key")
+	}
+	if validKey.Kid == "weak" || len(keyRing) != 1 {
+		t.Error("Weak key reached the key ring")
+	}
+}
+
+// Test exponent sanity checks
+func TestValidateKeyPair_Exponent(t *testing.T) {
+	for _, e := range []int{0, 1, 65536} {
+		if err := validateKeyPair(weakKeyPair(2048, e)); err == nil {
+			t.Errorf("Expected exponent %d to be rejected", e)
+		}
+	}
+	if err := validateKeyPair(weakKeyPair(2048, 65537)); err != nil {
+		t.Errorf("Expected a 2048-bit key with e=65537 to pass: %v", err)
+	}
+}
+
+// Test generated RSA and EC keys pass validation
+func TestValidateKeyPair_Generated(t *testing.T) {
+	rsaKey, _ := generateKeyPair(time.Now().Add(time.Hour), 2048)
+	ecKey, _ := generateECKeyPair(time.Now().Add(time.Hour), 0)
+	for _, kp := range []*KeyPair{rsaKey, ecKey} {
+		if err := validateKeyPair(kp); err != nil {
+			t.Errorf("Expected generated key to pass: %v", err)
+		}
+	}
+}
+
+// Test -min-rsa-bits can raise the floor but never lower it below 2048
+func TestParseFlags_MinRSABits(t *testing.T) {
+	if err := parseTestFlags(t, "-min-rsa-bits", "1024", "-rsa-bits", "1024"); err == nil {
+		t.Error("Expected a floor below 2048 to be rejected")
+	}
+	if err := parseTestFlags(t, "-min-rsa-bits", "3072", "-rsa-bits", "2048"); err == nil {
+		t.Error("Expected -rsa-bits below a raised floor to be rejected")
+	}
+	if err := parseTestFlags(t, "-min-rsa-bits", "3072", "-rsa-bits", "3072"); err != nil {
+		t.Errorf("Expected a raised floor to be accepted: %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"errors"
+	"fmt"
+)
+
+// Environment variable holding the passphrase for private keys at rest
+const passphraseEnv = "JWKS_KEY_PASSPHRASE"
+
+// Parameters for deriving the AES-256 key from a passphrase
+const (
+	kdfSaltSize   = 16
+	kdfIterations = 600000
+	aesKeySize    = 32
+)
+
+var errDecryptKey = errors.New("decrypt private key: wrong passphrase or corrupted data")
+
+// Passphrase from -key-passphrase or, when that is empty, the environment, set by
+// parseFlags; it decrypts encrypted -key-file blocks and encrypts -gen-key output
+var keyPassphrase []byte
+
+// Resolved -key-passphrase value
+var keyPassphraseFlag string
+
+// Encrypts a PKCS#1 private key with AES-256-GCM; output is salt|nonce|ciphertext
+func encryptPrivateKey(priv *rsa.PrivateKey, passphrase []byte) ([]byte, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("encrypt private key: empty passphrase")
+	}
+	salt := make([]byte, kdfSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	gcm, err := newKeyCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := append(salt, nonce...)
+	return gcm.Seal(out, nonce, x509.MarshalPKCS1PrivateKey(priv), salt), nil
+}
+
+// Reverses encryptPrivateKey, failing cleanly on a wrong passphrase or tampering
+func decryptPrivateKey(data, passphrase []byte) (*rsa.PrivateKey, error) {
+	if len(data) < kdfSaltSize {
+		return nil, errDecryptKey
+	}
+	salt := data[:kdfSaltSize]
+	gcm, err := newKeyCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	rest := data[kdfSaltSize:]
+	if len(rest) < gcm.NonceSize() {
+		return nil, errDecryptKey
+	}
+	plain, err := gcm.Open(nil, rest[:gcm.NonceSize()], rest[gcm.NonceSize():], salt)
+	if err != nil {
+		return nil, errDecryptKey
+	}
+	priv, err := x509.ParsePKCS1PrivateKey(plain)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt private key: %w", err)
+	}
+	return priv, nil
+}
+
+func newKeyCipher(passphrase, salt []byte) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, string(passphrase), salt, kdfIterations, aesKeySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
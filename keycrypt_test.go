@@ -0,0 +1,46 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+)
+
+// Test encrypt/decrypt round trip
+func TestEncryptPrivateKey_RoundTrip(t *testing.T) {
+	priv, _ := rsa.GenerateKey(rand.Reader, 2048)
+	data, err := encryptPrivateKey(priv, []byte("correct horse"))
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	got, err := decryptPrivateKey(data, []byte("correct horse"))
+	if err != nil {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+	if !got.Equal(priv) {
+		t.Error("Decrypted key does not match original")
+	}
+}
+
+// Test wrong passphrase yields a decryption error
+func TestDecryptPrivateKey_WrongPassphrase(t *testing.T) {
+	priv, _ := rsa.GenerateKey(rand.Reader, 2048)
+	data, _ := encryptPrivateKey(priv, []byte("correct horse"))
+	if _, err := decryptPrivateKey(data, []byte("battery staple")); !errors.Is(err, errDecryptKey) {
+		t.Errorf("Expected errDecryptKey, got %v", err)
+	}
+}
+
+// Test a flipped ciphertext byte is detected
+func TestDecryptPrivateKey_Tampered(t *testing.T) {
+	priv, _ := rsa.GenerateKey(rand.Reader, 2048)
+	data, _ := encryptPrivateKey(priv, []byte("correct horse"))
+	data[len(data)-1] ^= 0xff
+	if _, err := decryptPrivateKey(data, []byte("correct horse")); !errors.Is(err, errDecryptKey) {
+		t.Errorf("Expected errDecryptKey, got %v", err)
+	}
+	if _, err := decryptPrivateKey(data[:4], []byte("correct horse")); !errors.Is(err, errDecryptKey) {
+		t.Errorf("Expected errDecryptKey for truncated data, got %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Retry policy for transient key generation failures
+var (
+	keygenAttempts = 3
+	keygenBackoff  = 100 * time.Millisecond
+)
+
+// Calls generateKeyPairFunc up to keygenAttempts times, doubling the delay
+// from keygenBackoff after each failure, until it succeeds or ctx is done
+func generateWithRetry(ctx context.Context, expiresAt time.Time, bits int) (*KeyPair, error) {
+	return generateWithRetryUsing(ctx, generateKeyPairFunc, expiresAt, bits)
+}
+
+// generateWithRetry with an explicit generator
+func generateWithRetryUsing(ctx context.Context, generate func(time.Time, int) (*KeyPair, error), expiresAt time.Time, bits int) (*KeyPair, error) {
+	delay := keygenBackoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		var kp *KeyPair
+		if kp, err = generate(expiresAt, bits); err == nil {
+			return kp, nil
+		}
+		if attempt >= keygenAttempts {
+			break
+		}
+		logger.Warn("key generation failed, retrying", "attempt", attempt, "delay", delay, "error", err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+	return nil, fmt.Errorf("key generation failed after %d attempts: %w", keygenAttempts, err)
+}
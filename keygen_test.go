@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// Test initKeys survives a generator that fails twice
+func TestInitKeys_RetriesKeyGeneration(t *testing.T) {
+	keygenBackoff = time.Millisecond
+	defer func() { keygenBackoff = 100 * time.Millisecond }()
+	calls := 0
+	original := generateKeyPairFunc
+	generateKeyPairFunc = func(expiresAt time.Time, bits int) (*KeyPair, error) {
+		calls++
+		if calls <= 2 {
+			return nil, errors.New("transient failure")
+		}
+		return original(expiresAt, bits)
+	}
+	defer func() { generateKeyPairFunc = original }()
+
+	if err := initKeys(); err != nil {
+		t.Fatalf("Expected initKeys to succeed after retries: %v", err)
+	}
+	// The expired key is only generated with -enable-expired-endpoint
+	if calls != 3 {
+		t.Errorf("Expected 3 calls for the signing key, got %d", calls)
+	}
+}
+
+// Test retries stop after the configured attempts
+func TestGenerateWithRetry_GivesUp(t *testing.T) {
+	keygenAttempts, keygenBackoff = 2, time.Millisecond
+	defer func() { keygenAttempts, keygenBackoff = 3, 100*time.Millisecond }()
+	calls := 0
+	original := generateKeyPairFunc
+	generateKeyPairFunc = func(time.Time, int) (*KeyPair, error) {
+		calls++
+		return nil, errors.New("permanent failure")
+	}
+	defer func() { generateKeyPairFunc = original }()
+
+	if _, err := generateWithRetry(context.Background(), time.Now(), 2048); err == nil || calls != 2 {
+		t.Errorf("Expected failure after 2 calls, got %d calls (%v)", calls, err)
+	}
+}
+
+// Test a cancelled context interrupts the backoff
+func TestGenerateWithRetry_Cancelled(t *testing.T) {
+	keygenBackoff = time.Hour
+	defer func() { keygenBackoff = 100 * time.Millisecond }()
+	original := generateKeyPairFunc
+	generateKeyPairFunc = func(time.Time, int) (*KeyPair, error) { return nil, errors.New("failure") }
+	defer func() { generateKeyPairFunc = original }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := generateWithRetry(ctx, time.Now(), 2048); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,12 @@
+package main
+
+// Publish key_ops alongside use for verifiers that check it
+var emitKeyOps bool
+
+// Permitted operations for a published key with the given use
+func keyOpsFor(use string) []string {
+	if use == useEnc {
+		return []string{"encrypt"}
+	}
+	return []string{"verify"}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test key_ops matches each key's use when enabled
+func TestToJWK_KeyOps(t *testing.T) {
+	emitKeyOps = true
+	defer func() { emitKeyOps = false }()
+	kp, _ := generateKeyPair(time.Now().Add(time.Hour), 2048)
+
+	if ops := kp.toJWK(useSig).KeyOps; !slices.Equal(ops, []string{"verify"}) {
+		t.Errorf("Expected [verify] for a signing key, got %v", ops)
+	}
+	if ops := kp.toJWK(useEnc).KeyOps; !slices.Equal(ops, []string{"encrypt"}) {
+		t.Errorf("Expected [encrypt] for an encryption key, got %v", ops)
+	}
+}
+
+// Test key_ops is omitted by default
+func TestToJWK_NoKeyOpsByDefault(t *testing.T) {
+	kp, _ := generateKeyPair(time.Now().Add(time.Hour), 2048)
+	out, _ := json.Marshal(kp.toJWK(useSig))
+	if strings.Contains(string(out), "key_ops") {
+		t.Errorf("Expected no key_ops, got %s", out)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// Number of pre-generated keys kept ready for rotation (0 disables the pool)
+var keyPoolSize = 1
+
+// Ready keys for rotateKeys, filled by keyPoolLoop; nil when the pool is disabled
+var keyPool chan *KeyPair
+
+// Keeps pool full of freshly generated keys until ctx is cancelled. Each key
+// is generated holding a keygenSlots slot, so the pool counts towards
+// -max-keygen; the slot is released before waiting for room in the pool.
+func keyPoolLoop(ctx context.Context, pool chan<- *KeyPair) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case keygenSlots <- struct{}{}:
+		}
+		kp, err := generateWithRetry(ctx, nowFunc().Add(keyLifetime), rsaBits)
+		<-keygenSlots
+		if err == nil {
+			err = validateKeyPair(kp)
+		}
+		if err != nil {
+			logger.Error("key pool generation failed", "error", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(keygenBackoff):
+			}
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case pool <- kp:
+		}
+	}
+}
+
+// Takes a pooled key if one is ready, restamped to expire at expiresAt, so
+// time spent waiting in the pool does not shorten its lifetime
+func pooledKey(expiresAt time.Time) (*KeyPair, bool) {
+	var kp *KeyPair
+	select {
+	case kp = <-keyPool:
+	default:
+		return nil, false
+	}
+	kp.ExpiresAt = jitterExpiry(expiresAt)
+	if kp.Cert != nil {
+		cert, err := selfSignedCert(kp)
+		if err != nil {
+			logger.Error("re-issuing pooled key certificate failed", "kid", kp.Kid, "error", err)
+			return nil, false
+		}
+		kp.Cert = cert
+	}
+	return kp, true
+}
+
+// A pooled key when one is ready, otherwise one generated on the spot under
+// withKeygenSlot; a pooled key needs no slot
+func nextKey(expiresAt time.Time, wait bool) (*KeyPair, error) {
+	if kp, ok := pooledKey(expiresAt); ok {
+		return kp, nil
+	}
+	return withKeygenSlot(wait, func() (*KeyPair, error) {
+		return generateWithRetry(context.Background(), expiresAt, rsaBits)
+	})
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Test the loop fills the pool, rotation takes pooled keys and falls back to generating once it is drained
+func TestKeyPool_DrainFallsBackToGeneration(t *testing.T) {
+	validKey, keyRing = nil, nil
+	pool := make(chan *KeyPair, 2)
+	keyPool = pool
+	defer func() { keyPool = nil }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		keyPoolLoop(ctx, pool)
+		close(done)
+	}()
+	deadline := time.After(10 * time.Second)
+	for len(pool) < cap(pool) {
+		select {
+		case <-deadline:
+			t.Fatal("Pool was not filled")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	cancel()
+	<-done
+
+	pooled := map[string]bool{}
+	for _, kp := range []*KeyPair{<-pool, <-pool} {
+		pooled[kp.Kid] = true
+		pool <- kp
+	}
+	for range 2 {
+		kp, err := rotateKeys(true)
+		if err != nil || !pooled[kp.Kid] {
+			t.Fatalf("Expected a pooled key, got %v (%v)", kp, err)
+		}
+		if d := time.Until(kp.ExpiresAt); d < keyLifetime-time.Minute {
+			t.Errorf("Expected the pooled key restamped to a full lifetime, got %v", d)
+		}
+	}
+
+	if len(pool) != 0 {
+		t.Fatalf("Expected a drained pool, %d keys left", len(pool))
+	}
+	kp, err := rotateKeys(true)
+	if err != nil {
+		t.Fatalf("Expected rotation to generate a key on an empty pool: %v", err)
+	}
+	if pooled[kp.Kid] {
+		t.Error("Expected a freshly generated key")
+	}
+	if active, _ := keyStore.Active(); active != kp {
+		t.Error("Expected the generated key to become active")
+	}
+}
+
+// Test the pool worker and rotation together never exceed -max-keygen generations
+func TestKeyPool_RespectsKeygenSlots(t *testing.T) {
+	validKey, keyRing = nil, nil
+	keygenSlots = make(chan struct{}, 1)
+	pool := make(chan *KeyPair, 1)
+	keyPool = pool
+	var inFlight, peak atomic.Int32
+	original := generateKeyPairFunc
+	generateKeyPairFunc = func(expiresAt time.Time, bits int) (*KeyPair, error) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		time.Sleep(5 * time.Millisecond)
+		return original(expiresAt, bits)
+	}
+	defer func() { generateKeyPairFunc, keyPool = original, nil }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		keyPoolLoop(ctx, pool)
+		close(done)
+	}()
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Go(func() {
+			if _, err := rotateKeys(true); err != nil {
+				t.Errorf("Rotation failed: %v", err)
+			}
+		})
+	}
+	wg.Wait()
+	cancel()
+	<-done
+
+	if p := peak.Load(); p != 1 {
+		t.Errorf("Expected at most 1 concurrent generation, saw %d", p)
+	}
+}
+
+// Test a manual rotation takes a pooled key while a refill holds the only generation slot
+func TestKeyPool_RotateDuringRefill(t *testing.T) {
+	validKey, keyRing = nil, nil
+	keygenSlots = make(chan struct{}, 1)
+	adminToken = "s3cret"
+	ready, _ := generateKeyPair(time.Now().Add(time.Hour), 2048)
+	pool := make(chan *KeyPair, 1)
+	pool <- ready
+	keyPool = pool
+	started, release := make(chan struct{}), make(chan struct{})
+	original := generateKeyPairFunc
+	generateKeyPairFunc = func(expiresAt time.Time, bits int) (*KeyPair, error) {
+		close(started)
+		<-release
+		return original(expiresAt, bits)
+	}
+	defer func() { generateKeyPairFunc, keyPool, adminToken = original, nil, "" }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		keyPoolLoop(ctx, pool)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	<-started
+	defer close(release)
+
+	w := adminRequest("POST", "/admin/rotate", "s3cret")
+	if w.Code != 200 {
+		t.Fatalf("Expected the pooled key to rotate in during a refill, got %d: %s", w.Code, w.Body)
+	}
+	if active, _ := keyStore.Active(); active != ready {
+		t.Error("Expected the pooled key to become active")
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Storage backend for signing keys; swap in a persistent implementation to
+// share keys across restarts or instances
+type KeyStore interface {
+	// Active returns the current signing key, which may have expired
+	Active() (*KeyPair, error)
+	// SigningKey returns the active key if it can still sign at now, else
+	// errNoActiveKey; the key and its expiry check come from one snapshot
+	SigningKey(now time.Time) (*KeyPair, error)
+	// All returns every stored key, the active one included
+	All() ([]*KeyPair, error)
+	// Add stores kp and makes it the active signing key; a kid already
+	// stored is rejected with errDuplicateKid, and keys beyond
+	// maxRetainedKeys are dropped earliest expiry first
+	Add(kp *KeyPair) error
+	// Prune drops keys whose expiry plus jwksGrace is not after now and
+	// reports how many were removed
+	Prune(now time.Time) (int, error)
+}
+
+var errNoActiveKey = errors.New("no active signing key")
+
+// Returned by Add when the kid is already stored, so verifiers never see two keys with one kid
+var errDuplicateKid = errors.New("duplicate kid")
+
+// Most keys the in-memory store retains (0 for no limit); Add drops the earliest-expiring beyond it
+var maxRetainedKeys int
+
+// Guards validKey, keyRing, expiredKey and encKey, which handlers read while rotation writes
+var keyMu sync.RWMutex
+
+// Default store, backed by the validKey and keyRing globals
+type inMemoryStore struct{}
+
+var keyStore KeyStore = inMemoryStore{}
+
+func (inMemoryStore) Active() (*KeyPair, error) {
+	keyMu.RLock()
+	defer keyMu.RUnlock()
+	if validKey == nil {
+		return nil, errNoActiveKey
+	}
+	return validKey, nil
+}
+
+func (inMemoryStore) SigningKey(now time.Time) (*KeyPair, error) {
+	keyMu.RLock()
+	defer keyMu.RUnlock()
+	if validKey == nil || !now.Before(validKey.ExpiresAt) {
+		return nil, errNoActiveKey
+	}
+	return validKey, nil
+}
+
+func (inMemoryStore) All() ([]*KeyPair, error) {
+	keyMu.RLock()
+	defer keyMu.RUnlock()
+	return slices.Clone(keyRing), nil
+}
+
+func (inMemoryStore) Add(kp *KeyPair) error {
+	if kp == nil {
+		return errors.New("add key: nil key pair")
+	}
+	keyMu.Lock()
+	defer keyMu.Unlock()
+	if slices.ContainsFunc(keyRing, func(k *KeyPair) bool { return k != nil && k.Kid == kp.Kid }) {
+		return fmt.Errorf("add key %s: %w", kp.Kid, errDuplicateKid)
+	}
+	validKey = kp
+	setKeyRingLocked(capKeys(append(slices.Clone(keyRing), kp), kp))
+	return nil
+}
+
+// Drops the earliest-expiring keys beyond maxRetainedKeys, never keep
+func capKeys(ring []*KeyPair, keep *KeyPair) []*KeyPair {
+	excess := len(ring) - maxRetainedKeys
+	if maxRetainedKeys <= 0 || excess <= 0 {
+		return ring
+	}
+	oldest := slices.SortedFunc(slices.Values(ring), func(a, b *KeyPair) int {
+		return a.ExpiresAt.Compare(b.ExpiresAt)
+	})
+	oldest = slices.DeleteFunc(oldest, func(k *KeyPair) bool { return k == keep })[:excess]
+	return slices.DeleteFunc(ring, func(k *KeyPair) bool { return slices.Contains(oldest, k) })
+}
+
+func (inMemoryStore) Prune(now time.Time) (int, error) {
+	keyMu.Lock()
+	defer keyMu.Unlock()
+	var kept []*KeyPair
+	for _, kp := range keyRing {
+		if kp != nil && now.Before(kp.ExpiresAt.Add(jwksGrace)) {
+			kept = append(kept, kp)
+		}
+	}
+	removed := len(keyRing) - len(kept)
+	setKeyRingLocked(kept)
+	return removed, nil
+}
+
+// Key used for ?expired test tokens, or nil
+func currentExpiredKey() *KeyPair {
+	keyMu.RLock()
+	defer keyMu.RUnlock()
+	return expiredKey
+}
+
+func setExpiredKey(kp *KeyPair) {
+	keyMu.Lock()
+	defer keyMu.Unlock()
+	expiredKey = kp
+}
+
+// Active key if it can still sign at now
+func signingKeyAt(now time.Time) (*KeyPair, bool) {
+	kp, err := keyStore.SigningKey(now)
+	return kp, err == nil
+}
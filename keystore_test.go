@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// Test Active reports a missing key and then the most recently added one
+func TestInMemoryStore_Active(t *testing.T) {
+	validKey, keyRing = nil, nil
+	store := inMemoryStore{}
+	if _, err := store.Active(); !errors.Is(err, errNoActiveKey) {
+		t.Errorf("Expected errNoActiveKey, got %v", err)
+	}
+	kp, _ := generateKeyPair(time.Now().Add(time.Hour), 2048)
+	store.Add(kp)
+	if got, err := store.Active(); err != nil || got != kp {
+		t.Errorf("Expected the added key, got %v (%v)", got, err)
+	}
+}
+
+// Test All returns every key as a copy
+func TestInMemoryStore_All(t *testing.T) {
+	validKey, keyRing = nil, nil
+	store := inMemoryStore{}
+	k1, _ := generateKeyPair(time.Now().Add(time.Hour), 2048)
+	k2, _ := generateKeyPair(time.Now().Add(2*time.Hour), 2048)
+	store.Add(k1)
+	store.Add(k2)
+	all, err := store.All()
+	if err != nil || len(all) != 2 || all[0] != k1 || all[1] != k2 {
+		t.Fatalf("Expected [k1 k2], got %v (%v)", all, err)
+	}
+	all[0] = nil
+	if again, _ := store.All(); again[0] != k1 {
+		t.Error("Expected All to return a copy")
+	}
+}
+
+// Test Add demotes the previous active key without dropping it
+func TestInMemoryStore_Add(t *testing.T) {
+	validKey, keyRing = nil, nil
+	store := inMemoryStore{}
+	old, _ := generateKeyPair(time.Now().Add(time.Hour), 2048)
+	store.Add(old)
+	kp, _ := generateKeyPair(time.Now().Add(2*time.Hour), 2048)
+	if err := store.Add(kp); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if active, _ := store.Active(); active != kp {
+		t.Error("Expected the new key to become active")
+	}
+	if _, ok := findKeyByKid(old.Kid); !ok {
+		t.Error("Expected the demoted key to remain stored")
+	}
+	if err := store.Add(nil); err == nil {
+		t.Error("Expected adding a nil key to fail")
+	}
+}
+
+// Test a second key with an existing kid is rejected and not published
+func TestInMemoryStore_AddDuplicateKid(t *testing.T) {
+	validKey, keyRing = nil, nil
+	store := inMemoryStore{}
+	first, _ := generateKeyPair(time.Now().Add(time.Hour), 2048)
+	second, _ := generateKeyPair(time.Now().Add(2*time.Hour), 2048)
+	second.Kid = first.Kid
+	if err := store.Add(first); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := store.Add(second); !errors.Is(err, errDuplicateKid) {
+		t.Errorf("Expected errDuplicateKid, got %v", err)
+	}
+	if all, _ := store.All(); len(all) != 1 || all[0] != first {
+		t.Errorf("Expected only the first key stored, got %v", all)
+	}
+	if active, _ := store.Active(); active != first {
+		t.Error("Expected the first key to stay active")
+	}
+}
+
+// Test Prune drops keys past expiry plus grace
+func TestInMemoryStore_Prune(t *testing.T) {
+	validKey, keyRing = nil, nil
+	jwksGrace = 10 * time.Minute
+	defer func() { jwksGrace = 0 }()
+	store := inMemoryStore{}
+	now := time.Now()
+	gone, _ := generateKeyPair(now.Add(-time.Hour), 2048)
+	grace, _ := generateKeyPair(now.Add(-time.Minute), 2048)
+	live, _ := generateKeyPair(now.Add(time.Hour), 2048)
+	for _, kp := range []*KeyPair{gone, grace, live} {
+		store.Add(kp)
+	}
+	if removed, err := store.Prune(now); err != nil || removed != 1 {
+		t.Fatalf("Expected 1 key pruned, got %d (err %v)", removed, err)
+	}
+	all, _ := store.All()
+	if len(all) != 2 || all[0] != grace || all[1] != live {
+		t.Errorf("Expected only the in-grace and live keys, got %v", all)
+	}
+}
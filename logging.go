@@ -0,0 +1,62 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Structured request logger; swapped out by tests
+var logger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+// Captures the status code and body size written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (sr *statusRecorder) WriteHeader(code int) {
+	sr.status = code
+	sr.ResponseWriter.WriteHeader(code)
+}
+
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	n, err := sr.ResponseWriter.Write(b)
+	sr.bytes += int64(n)
+	return n, err
+}
+
+// Logs method, path, status and latency for every request under a fresh request ID,
+// and appends the request to the access log when one is configured
+func withLogging(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		requestID := uuid.New().String()
+		w.Header().Set("X-Request-ID", requestID)
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+		latency := float64(time.Since(start).Microseconds()) / 1000
+		logger.Info("request",
+			"request_id", requestID,
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.status,
+			"latency_ms", latency,
+		)
+		if accessLog != nil {
+			accessLog.log(accessEntry{
+				Time:      start.UTC(),
+				Method:    r.Method,
+				Path:      r.URL.Path,
+				Status:    rec.status,
+				Bytes:     rec.bytes,
+				LatencyMS: latency,
+				RequestID: requestID,
+			})
+		}
+	}
+}
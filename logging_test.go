@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+)
+
+// Test the logging middleware emits structured fields and the request ID header
+func TestWithLogging(t *testing.T) {
+	var buf bytes.Buffer
+	original := logger
+	logger = slog.New(slog.NewJSONHandler(&buf, nil))
+	defer func() { logger = original }()
+
+	w := httptest.NewRecorder()
+	withLogging(healthHandler)(w, httptest.NewRequest("POST", "/healthz", nil))
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Log line is not JSON: %v", err)
+	}
+	for _, field := range []string{"request_id", "method", "path", "status", "latency_ms"} {
+		if _, ok := entry[field]; !ok {
+			t.Errorf("Missing log field %q in %v", field, entry)
+		}
+	}
+	if entry["method"] != "POST" || entry["path"] != "/healthz" || entry["status"] != float64(405) {
+		t.Errorf("Unexpected log values: %v", entry)
+	}
+	if id := w.Header().Get("X-Request-ID"); id == "" || id != entry["request_id"] {
+		t.Errorf("X-Request-ID %q does not match logged ID %v", id, entry["request_id"])
+	}
+}
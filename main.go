@@ -1,35 +1,63 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/tls"
 	"encoding/base64"
-	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
+	"maps"
 	"math/big"
 	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
 )
-// Data structures for RSA key pair management
+// Data structures for RSA and EC key pair management
 type KeyPair struct {
 	Kid        string
+	Alg        string
 	PrivateKey *rsa.PrivateKey
 	PublicKey  *rsa.PublicKey
+	ECKey      *ecdsa.PrivateKey
 	ExpiresAt  time.Time
+	// DER self-signed certificate, present when -emit-x5c is set
+	Cert []byte
 }
 
-// JSON Web Key format for JWKS response
+// JSON Web Key format for JWKS response; MarshalJSON (jwkjson.go) fixes the member order
 type JWK struct {
 	Kty string `json:"kty"`
 	Kid string `json:"kid"`
 	Use string `json:"use"`
 	Alg string `json:"alg"`
-	N   string `json:"n"`
-	E   string `json:"e"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+
+	KeyOps  []string `json:"key_ops,omitempty"`
+	X5C     []string `json:"x5c,omitempty"`
+	X5TS256 string   `json:"x5t#S256,omitempty"`
 }
 
 // JSON Web key set containing multiple JWKSs
@@ -39,90 +67,762 @@ type JWKS struct {
 
 // Global key storage and test injection points
 var (
-	validKey   *KeyPair
+	// Active key of the in-memory KeyStore; use keyStore rather than reading it directly
+	validKey *KeyPair
+	// Already-expired key for ?expired test tokens; read with currentExpiredKey
 	expiredKey *KeyPair
+	// Serve /auth?expired; off by default so production never mints expired tokens
+	enableExpiredEndpoint bool
+	// Published keys of the in-memory KeyStore; may hold several non-expired keys during rotation
+	keyRing    []*KeyPair
+	rsaBits    = 2048
+	signingAlg = "RS256"
+	// How long a key stays in the JWKS after it expires
+	jwksGrace time.Duration
+	// Deadline for a single signFunc call
+	signTimeout = 5 * time.Second
+	// How far nbf is set before iat
+	nbfSkew time.Duration
+	// Audiences stamped into every token as the aud claim
+	audience []string
+	// typ header of issued tokens, e.g. JWT or at+jwt (RFC 9068)
+	tokenTyp = "JWT"
 	// Test injection points
+	// Clock behind every expiry, iat and key-validity decision; tests freeze or advance it
+	nowFunc             = time.Now
 	generateKeyPairFunc = generateKeyPair
-	signFunc            = func(k *rsa.PrivateKey, _ jwt.SigningMethod, token *jwt.Token) (string, error) {
+	signFunc            = func(_ context.Context, k crypto.PrivateKey, _ jwt.SigningMethod, token *jwt.Token) (string, error) {
 		return token.SignedString(k)
 	}
+	// Business rules checked right before signing; an error rejects the token with 403
+	validateClaims = func(jwt.MapClaims) error { return nil }
 )
 
+// Wraps a validateClaims error so handlers can answer 403
+var errClaimsRejected = errors.New("claims rejected")
+
 // Key generation utilities
-func generateKeyPair(expiresAt time.Time) (*KeyPair, error) {
-	key, err := rsa.GenerateKey(rand.Reader, 2048)
+
+// JWS alg for RSA keys: PS256 when selected with -alg, otherwise RS256
+func rsaAlg() string {
+	if signingAlg == "PS256" {
+		return "PS256"
+	}
+	return "RS256"
+}
+func generateKeyPair(expiresAt time.Time, bits int) (*KeyPair, error) {
+	return generateKeyPairFrom(rand.Reader, expiresAt, bits)
+}
+
+// generateKeyPair drawing randomness from random. crypto/rand.Reader takes the
+// rsa.GenerateKey path; any other reader, such as a seeded one in tests, has
+// the key derived from its stream so the same seed always gives the same key.
+func generateKeyPairFrom(random io.Reader, expiresAt time.Time, bits int) (*KeyPair, error) {
+	var key *rsa.PrivateKey
+	var err error
+	if random == rand.Reader {
+		key, err = rsa.GenerateKey(random, bits)
+	} else {
+		key, err = deriveRSAKey(random, bits)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return finalizeKeyPair(&KeyPair{Alg: rsaAlg(), PrivateKey: key, PublicKey: &key.PublicKey, ExpiresAt: jitterExpiry(expiresAt)})
+}
+
+// EC keys are always P-256, so the RSA size is ignored
+func generateECKeyPair(expiresAt time.Time, _ int) (*KeyPair, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	if err != nil {
 		return nil, err
 	}
-	return &KeyPair{uuid.New().String(), key, &key.PublicKey, expiresAt}, nil
+	return finalizeKeyPair(&KeyPair{Alg: "ES256", ECKey: key, ExpiresAt: jitterExpiry(expiresAt)})
+}
+
+// Completes a new key pair: assigns its kid and, with -emit-x5c, a self-signed certificate
+func finalizeKeyPair(kp *KeyPair) (*KeyPair, error) {
+	assignKid(kp)
+	if emitX5C {
+		cert, err := selfSignedCert(kp)
+		if err != nil {
+			return nil, err
+		}
+		kp.Cert = cert
+	}
+	return kp, nil
+}
+
+// Private key handed to the signer, whichever type the pair holds
+func (kp *KeyPair) signingKey() crypto.PrivateKey {
+	if kp.ECKey != nil {
+		return kp.ECKey
+	}
+	return kp.PrivateKey
+}
+
+// Public key used to verify tokens signed by this pair
+func (kp *KeyPair) verificationKey() crypto.PublicKey {
+	if kp.ECKey != nil {
+		return &kp.ECKey.PublicKey
+	}
+	return kp.PublicKey
+}
+
+// JWS signing method for this pair, from its key type and Alg
+func (kp *KeyPair) signingMethod() jwt.SigningMethod {
+	if kp.ECKey != nil {
+		return jwt.SigningMethodES256
+	}
+	if kp.Alg == "PS256" {
+		return jwt.SigningMethodPS256
+	}
+	return jwt.SigningMethodRS256
+}
+
+// Minimal big-endian encoding of an RSA exponent, no leading zero bytes (RFC 7518)
+func exponentBytes(e int) []byte {
+	var b []byte
+	for v := uint64(e); v > 0; v >>= 8 {
+		b = append([]byte{byte(v)}, b...)
+	}
+	return b
+}
+
+// Minimal big-endian encoding of a big integer such as an RSA modulus, with any
+// leading zero bytes stripped (RFC 7518); zero encodes as a single zero byte
+func minimalBytes(n *big.Int) []byte {
+	b := bytes.TrimLeft(n.Bytes(), "\x00")
+	if len(b) == 0 {
+		return []byte{0}
+	}
+	return b
+}
+
+func (kp *KeyPair) toJWK(use string) JWK {
+	var jwk JWK
+	if kp.ECKey != nil {
+		// Uncompressed point: 0x04 || X || Y, each coordinate 32 bytes for P-256
+		pt, _ := kp.ECKey.PublicKey.Bytes()
+		x := base64.RawURLEncoding.EncodeToString(pt[1:33])
+		y := base64.RawURLEncoding.EncodeToString(pt[33:])
+		jwk = JWK{Kty: "EC", Kid: kp.Kid, Use: use, Alg: kp.signingMethod().Alg(), Crv: "P-256", X: x, Y: y}
+	} else {
+		n := base64.RawURLEncoding.EncodeToString(minimalBytes(kp.PublicKey.N))
+		e := base64.RawURLEncoding.EncodeToString(exponentBytes(kp.PublicKey.E))
+		jwk = JWK{Kty: "RSA", Kid: kp.Kid, Use: use, Alg: kp.signingMethod().Alg(), N: n, E: e}
+		if use == useEnc {
+			jwk.Alg = "RSA-OAEP-256"
+		}
+	}
+	if emitKeyOps {
+		jwk.KeyOps = keyOpsFor(use)
+	}
+	if len(kp.Cert) > 0 {
+		addX5C(&jwk, kp.Cert)
+	}
+	return jwk
+}
+
+// Splits a comma-separated flag value, dropping empty entries
+func splitList(s string) []string {
+	var auds []string
+	for _, a := range strings.Split(s, ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			auds = append(auds, a)
+		}
+	}
+	return auds
+}
+
+// Token lifetime bounds for /auth
+const (
+	defaultTokenTTL = time.Hour
+	maxTokenTTL     = 24 * time.Hour
+)
+
+// Parses a requested token TTL, clamping it to maxTokenTTL
+func parseTTL(s string, def time.Duration) (time.Duration, error) {
+	if s == "" {
+		return def, nil
+	}
+	ttl, err := time.ParseDuration(s)
+	if err != nil || ttl <= 0 {
+		return 0, fmt.Errorf("invalid ttl %q", s)
+	}
+	return min(ttl, maxTokenTTL), nil
+}
+
+// Expiry for a token so it never outlives its signing key
+func tokenExpiry(now time.Time, ttl time.Duration, kp *KeyPair) int64 {
+	exp := now.Add(ttl)
+	if kp.ExpiresAt.Before(exp) {
+		exp = kp.ExpiresAt
+	}
+	return exp.Unix()
+}
+
+// Keys in the ring still published at now, including those within the grace period,
+// ordered by expiry then kid so the same set always serializes identically
+func publishedKeys(now time.Time) []*KeyPair {
+	return sortKeys(keysValidAt(now, jwksGrace))
+}
+
+// Orders keys by expiry then kid, in place
+func sortKeys(keys []*KeyPair) []*KeyPair {
+	slices.SortFunc(keys, func(a, b *KeyPair) int {
+		if c := a.ExpiresAt.Compare(b.ExpiresAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Kid, b.Kid)
+	})
+	return keys
+}
+
+// Finds the active signing key or a published (possibly demoted) key by kid
+func findKeyByKid(kid string) (*KeyPair, bool) {
+	if kp, err := keyStore.Active(); err == nil && kp.Kid == kid {
+		return kp, true
+	}
+	all, _ := keyStore.All()
+	for _, kp := range all {
+		if kp != nil && kp.Kid == kid {
+			return kp, true
+		}
+	}
+	return nil, false
+}
+
+// Keys in the store whose expiry plus grace is still after now; a failing store publishes nothing
+func keysValidAt(now time.Time, grace time.Duration) []*KeyPair {
+	var keys []*KeyPair
+	all, _ := keyStore.All()
+	for _, kp := range all {
+		if kp != nil && now.Before(kp.ExpiresAt.Add(grace)) {
+			keys = append(keys, kp)
+		}
+	}
+	return keys
+}
+
+// How far ahead ?future=true sets nbf, well beyond the default -introspect-leeway
+const futureNBFOffset = 5 * time.Minute
+
+// Parses an optional boolean query parameter; absent or empty is false
+func queryBool(r *http.Request, name string) (bool, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s value %q; use true or false", name, v)
+	}
+	return b, nil
 }
 
-func (kp *KeyPair) toJWK() JWK {
-	n := base64.RawURLEncoding.EncodeToString(kp.PublicKey.N.Bytes())
-	e := base64.RawURLEncoding.EncodeToString(big.NewInt(int64(kp.PublicKey.E)).Bytes())
-	return JWK{"RSA", kp.Kid, "sig", "RS256", n, e}
+// Signing algorithms the server supports, for -alg and the JWKS ?alg filter
+var signingAlgs = []string{"RS256", "PS256", "ES256"}
+
+// Keys whose signing alg is alg
+func keysWithAlg(keys []*KeyPair, alg string) []*KeyPair {
+	return slices.DeleteFunc(slices.Clone(keys), func(kp *KeyPair) bool {
+		return kp.signingMethod().Alg() != alg
+	})
 }
 
 // HTTP handlers for JWKS and authentication endpoints 
 func jwksHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "GET" {
-		http.Error(w, "Method not allowed", 405)
+		respondError(w, 405, errors.New("use GET"))
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	var keys []JWK
-	if validKey != nil && time.Now().Before(validKey.ExpiresAt) {
-		keys = append(keys, validKey.toJWK())
+	alg := r.URL.Query().Get("alg")
+	if alg != "" && !slices.Contains(signingAlgs, alg) {
+		respondError(w, 400, fmt.Errorf("unsupported alg %q; use one of %s", alg, strings.Join(signingAlgs, ", ")))
+		return
+	}
+	jwksRequests.Inc()
+	now := nowFunc()
+	published := publishedKeys(now)
+	enc := publishedEncKey(now)
+	if alg != "" {
+		// Only signing keys match an alg filter
+		published, enc = keysWithAlg(published, alg), nil
+	}
+	cached := published
+	if enc != nil {
+		cached = append(slices.Clip(published), enc)
+	}
+	if len(published) == 0 && alg == "" {
+		reportEmptyJWKS(w, now)
+	}
+	etag := jwksETag(cached)
+	setJWKSCacheHeaders(w, etag, jwksMaxAge(cached, now))
+	if etagMatches(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", jwksContentType(r))
+	if err := streamJWKS(w, jwkSeq(published, enc)); err != nil {
+		logResponseError(w, r, err)
 	}
-	json.NewEncoder(w).Encode(JWKS{keys})
 }
 
+// Minimum gap between "empty JWKS" warnings
+const emptyJWKSWarnInterval = time.Minute
+
+var (
+	emptyJWKSMu       sync.Mutex
+	lastEmptyJWKSWarn time.Time
+)
+
+// Explains an empty key set in X-JWKS-Empty-Reason and logs a rate-limited
+// warning, so "all tokens suddenly invalid" incidents are easy to diagnose
+func reportEmptyJWKS(w http.ResponseWriter, now time.Time) {
+	reason := "no signing key"
+	if kp, err := keyStore.Active(); err == nil {
+		reason = fmt.Sprintf("signing key %s expired at %s", kp.Kid, kp.ExpiresAt.UTC().Format(time.RFC3339))
+	} else if kp := currentExpiredKey(); kp != nil {
+		reason = fmt.Sprintf("no signing key; only expired key %s", kp.Kid)
+	}
+	w.Header().Set("X-JWKS-Empty-Reason", reason)
+
+	emptyJWKSMu.Lock()
+	defer emptyJWKSMu.Unlock()
+	if now.Sub(lastEmptyJWKSWarn) >= emptyJWKSWarnInterval {
+		lastEmptyJWKSWarn = now
+		logger.Warn("serving empty JWKS", "reason", reason)
+	}
+}
+
+// Assembles the JWKS document from the published signing keys and optional encryption key
+func buildJWKS(published []*KeyPair, enc *KeyPair) JWKS {
+	return JWKS{slices.AppendSeq([]JWK{}, jwkSeq(published, enc))}
+}
+
+// Serves a single published signing key by kid at /jwks/{kid}
+func jwkHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		respondError(w, 405, errors.New("use GET"))
+		return
+	}
+	kp, ok := findKeyByKid(r.PathValue("kid"))
+	if !ok || !nowFunc().Before(kp.ExpiresAt.Add(jwksGrace)) {
+		respondError(w, 404, errors.New("No published key with that kid"))
+		return
+	}
+	if err := respondJSON(w, 200, kp.toJWK(useSig)); err != nil {
+		logResponseError(w, r, err)
+	}
+}
 func authHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "POST" {
-		http.Error(w, "Method not allowed", 405)
+	serveAuth(w, r, nil)
+}
+
+// Authenticates the caller and responds with a token from t's key ring and
+// issuer, or from the server's own when t is nil. Only the server's own
+// issuer offers ?expired test tokens and refresh tokens.
+func serveAuth(w http.ResponseWriter, r *http.Request, t *tenant) {
+	defer prometheus.NewTimer(authLatency).ObserveDuration()
+	if r.Method != "POST" && r.Method != "GET" {
+		respondError(w, 405, errors.New("use GET or POST"))
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	
+	if !ready.Load() {
+		w.Header().Set("Retry-After", "1")
+		respondError(w, 503, errWarmingUp)
+		return
+	}
+
+	wantExpired, err := queryBool(r, "expired")
+	if err != nil {
+		respondError(w, 400, err)
+		return
+	}
+	wantFuture, err := queryBool(r, "future")
+	if err != nil {
+		respondError(w, 400, err)
+		return
+	}
+	if wantExpired && wantFuture {
+		respondError(w, 400, errors.New("expired and future cannot be combined"))
+		return
+	}
+	if wantExpired || wantFuture {
+		param := "expired"
+		if wantFuture {
+			param = "future"
+		}
+		if t != nil {
+			respondError(w, 404, fmt.Errorf("?%s=true is not available for tenants", param))
+			return
+		}
+		if !enableExpiredEndpoint {
+			respondError(w, 404, fmt.Errorf("?%s=true is disabled; start the server with -enable-expired-endpoint", param))
+			return
+		}
+	}
 	var keyToUse *KeyPair
-	var exp int64
-	if r.URL.Query().Get("expired") != "" && expiredKey != nil {
-		keyToUse, exp = expiredKey, expiredKey.ExpiresAt.Unix()
-	} else if validKey != nil {
-		keyToUse, exp = validKey, time.Now().Add(time.Hour).Unix()
+	var nbf, exp int64
+	// Whether a signature was reserved against -rotate-after-signs
+	var reserved bool
+	// One timestamp for the whole request: the key chosen here is still valid when the token is stamped
+	now := nowFunc()
+	iss := issuer
+	active, ok := signingKeyAt(now)
+	if t != nil {
+		iss = t.Issuer
+		active, ok = t.signingKeyAt(now)
+	}
+	if expired := currentExpiredKey(); wantExpired && expired != nil {
+		keyToUse, exp = expired, expired.ExpiresAt.Unix()
+	} else if ok {
+		keyToUse = active
 	} else {
-		http.Error(w, "No keys available", 500)
+		writeNoSigningKey(w, r)
 		return
 	}
 
-	claims := jwt.MapClaims{"sub": "user123", "exp": exp, "iat": time.Now().Unix()}
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
-	token.Header["kid"] = keyToUse.Kid
-	
-	tokenString, err := signFunc(keyToUse.PrivateKey, jwt.SigningMethodRS256, token)
+	sub, aud := "user123", audience
+	trusted, fromHeader := trustedSubject(r)
+	if fromHeader {
+		sub = trusted
+	}
+	if keyToUse == active {
+		var creds Credentials
+		// An upstream that set the trusted subject header has already authenticated the caller
+		if !fromHeader {
+			// A JSON body wins over Basic credentials; GET only takes Basic
+			basicUser, basicPass, basic := r.BasicAuth()
+			if r.Method == "POST" && (!basic || r.ContentLength != 0) {
+				if err := decodeJSON(w, r, &creds); err != nil {
+					writeDecodeError(w, err)
+					return
+				}
+				basic = false
+			} else {
+				creds.Username, creds.Password = basicUser, basicPass
+			}
+			if !checkCredentials(creds.Username, creds.Password) {
+				if r.Method == "GET" || basic {
+					w.Header().Set("WWW-Authenticate", `Basic realm="jwks-server"`)
+				}
+				respondError(w, 401, errors.New("invalid credentials"))
+				return
+			}
+			sub = creds.Username
+		}
+		if ok, retry := quotas.allow(sub, quotaLimit, quotaWindow); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(retry.Seconds())+1))
+			respondError(w, 429, errors.New("token quota exceeded for subject"))
+			return
+		}
+		requestedAud := r.URL.Query().Get("audience")
+		if creds.Audience != "" {
+			requestedAud = creds.Audience
+		}
+		if requestedAud != "" {
+			if !audienceAllowed(requestedAud) {
+				respondError(w, 400, fmt.Errorf("audience %q is not configured", requestedAud))
+				return
+			}
+			aud = []string{requestedAud}
+		}
+
+		requested := r.URL.Query().Get("ttl")
+		if creds.TTL != "" {
+			requested = creds.TTL
+		}
+		ttl, err := parseTTL(requested, ttlForAudience(requestedAud))
+		if err != nil {
+			respondError(w, 400, err)
+			return
+		}
+		// Reserved only once the request has passed validation, so rejected
+		// requests never use up the budget or force a rotation
+		if rotateAfterSigns > 0 && t == nil {
+			kp, err := signingKeyWithBudget(keyToUse)
+			if err != nil {
+				logger.Error("rotation after signature limit failed", "error", err)
+				respondError(w, 500, newProblem(500, "rotation_failed", "Internal Server Error", "Failed to rotate the signing key"))
+				return
+			}
+			keyToUse, active, reserved = kp, kp, true
+		}
+		start := now
+		if wantFuture {
+			// The lifetime starts at nbf so the token is valid for ttl once it becomes valid
+			start = start.Add(futureNBFOffset)
+			nbf = start.Unix()
+		}
+		exp = tokenExpiry(start, ttl, keyToUse)
+	}
+
+	tokenString, err := issueTokenFor(withRequestTime(withSourceIP(r), now), iss, keyToUse, sub, aud, nbf, exp)
 	if err != nil {
-		http.Error(w, "Failed to sign token", 500)
+		if reserved {
+			// Nothing was signed, so the signature goes back to the budget
+			releaseSign(keyToUse)
+		}
+		writeSignError(w, r, err)
+		return
+	}
+	if tokenString, err = encryptToken(tokenString); err != nil {
+		logger.Error("token encryption failed", "error", err)
+		respondError(w, 500, newProblem(500, "encryption_failed", "Internal Server Error", "Failed to encrypt token"))
+		return
+	}
+	resp := map[string]string{
+		"token":          tokenString,
+		"kid":            keyToUse.Kid,
+		"key_expires_at": keyToUse.ExpiresAt.UTC().Format(time.RFC3339),
+	}
+	if authJWKSURI {
+		path := "/.well-known/jwks.json"
+		if t != nil {
+			path = "/tenants/" + t.Name + path
+		}
+		resp["jwks_uri"] = absoluteURL(path)
+	}
+	if keyToUse == active && t == nil {
+		if resp["refresh_token"], err = refreshTokens.issue(sub, ""); err != nil {
+			respondError(w, 500, errors.New("failed to issue refresh token"))
+			return
+		}
+	}
+	if err := respondJSON(w, 200, resp); err != nil {
+		logResponseError(w, r, err)
+	}
+}
+
+// Builds and signs an access token for sub with kp, giving up after signTimeout
+func issueToken(ctx context.Context, kp *KeyPair, sub string, aud []string, exp int64) (string, error) {
+	return issueTokenFor(ctx, issuer, kp, sub, aud, 0, exp)
+}
+
+// issueToken for an explicit iss, such as a tenant's issuer, and nbf; an nbf
+// of 0 means now backdated by nbfSkew
+func issueTokenFor(ctx context.Context, iss string, kp *KeyPair, sub string, aud []string, nbf, exp int64) (string, error) {
+	if !subPattern.MatchString(sub) {
+		return "", fmt.Errorf("%w %q: must match %s", errInvalidSubject, sub, subPattern)
+	}
+	method := kp.signingMethod()
+	// No token may outlive the key that verifies it
+	exp = min(exp, kp.ExpiresAt.Unix())
+	now := requestTime(ctx).Unix()
+	if nbf == 0 {
+		// Backdate nbf to tolerate verifiers with slow clocks
+		nbf = now - int64(nbfSkew.Seconds())
+	}
+	// Never past exp
+	nbf = min(nbf, exp)
+	claims := jwt.MapClaims{}
+	maps.Copy(claims, customClaims)
+	maps.Copy(claims, jwt.MapClaims{"iss": iss, "sub": sub, "exp": exp, "iat": now, "nbf": nbf, "jti": uuid.New().String()})
+	if len(aud) > 0 {
+		claims["aud"] = aud
+	} else {
+		// Custom claims never supply aud
+		delete(claims, "aud")
+	}
+	if err := validateClaims(claims); err != nil {
+		return "", fmt.Errorf("%w: %w", errClaimsRejected, err)
+	}
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kp.Kid
+	token.Header["typ"] = tokenTyp
+	
+	ctx, cancel := context.WithTimeout(ctx, signTimeout)
+	defer cancel()
+	type result struct {
+		token string
+		err   error
+	}
+	// Buffered so a signer that ignores ctx can still finish after we give up
+	done := make(chan result, 1)
+	sign := signFunc
+	go func() {
+		s, err := sign(ctx, kp.signingKey(), method, token)
+		done <- result{s, err}
+	}()
+	var res result
+	select {
+	case res = <-done:
+	case <-ctx.Done():
+		res.err = ctx.Err()
+	}
+	if res.err != nil {
+		signingFailures.Inc()
+		return "", res.err
+	}
+	tokensIssued.Inc()
+	audit.record(AuditEntry{JTI: claims["jti"].(string), Sub: sub, IssuedAt: now, Exp: exp, SourceIP: sourceIP(ctx)})
+	return res.token, nil
+}
+
+// Maps an issueToken error to 400 for an invalid subject, 403 for rejected claims, 504 on timeout and 500 otherwise
+func writeSignError(w http.ResponseWriter, r *http.Request, err error) {
+	p := signProblem(r, err)
+	respondError(w, p.Status, p)
+}
+
+func signProblem(r *http.Request, err error) *Problem {
+	if errors.Is(err, errClaimsRejected) {
+		return newProblem(403, "claims_rejected", "Forbidden", err.Error())
+	}
+	if errors.Is(err, errInvalidSubject) {
+		return newProblem(400, "", "Bad Request", err.Error())
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		logger.Error("token signing timed out", "path", r.URL.Path, "timeout", signTimeout)
+		return newProblem(504, "signing_timeout", "Gateway Timeout", "Signing timed out")
+	}
+	logger.Error("token signing failed", "path", r.URL.Path, "error", err)
+	return newProblem(500, "signing_failed", "Internal Server Error", "Failed to sign token")
+}
+
+// Reports a missing or expired signing key, a configuration problem rather than a crypto one
+func writeNoSigningKey(w http.ResponseWriter, r *http.Request) {
+	respondError(w, 500, noSigningKeyProblem(r))
+}
+
+func noSigningKeyProblem(r *http.Request) *Problem {
+	logger.Error("no signing key available", "path", r.URL.Path)
+	return newProblem(500, "no_signing_key", "Internal Server Error", "No keys available")
+}
+
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		respondError(w, 405, errors.New("use GET"))
 		return
 	}
-	json.NewEncoder(w).Encode(map[string]string{"token": tokenString})
+	now := nowFunc()
+	count := len(keysValidAt(now, 0))
+	status, code := "ok", 200
+	if !ready.Load() {
+		status, code = "not ready", 503
+	} else if _, ok := signingKeyAt(now); !ok {
+		status, code = "unavailable", 503
+	}
+	if err := respondJSON(w, code, map[string]any{"status": status, "keys": count}); err != nil {
+		logResponseError(w, r, err)
+	}
 }
 
+// How long a newly created signing key stays valid
+const keyLifetime = 24 * time.Hour
+
 // Server initialization and startup 
 func initKeys() error {
+	if rsaBits < minRSABits {
+		return fmt.Errorf("rsa key size %d is below the minimum of %d bits", rsaBits, minRSABits)
+	}
+	var kp *KeyPair
 	var err error
-	if validKey, err = generateKeyPairFunc(time.Now().Add(24 * time.Hour)); err != nil {
+	if keyFile != "" {
+		kp, err = loadKeyPairFile(keyFile, nowFunc().Add(keyLifetime))
+	} else {
+		kp, err = generateWithRetry(context.Background(), nowFunc().Add(keyLifetime), rsaBits)
+	}
+	if err == nil {
+		err = validateKeyPair(kp)
+	}
+	if err == nil {
+		err = keyStore.Add(kp)
+	}
+	if err != nil {
+		return err
+	}
+	if err := refreshEncKey(kp.ExpiresAt); err != nil {
 		return err
 	}
-	expiredKey, err = generateKeyPairFunc(time.Now().Add(-time.Hour))
-	return err
+	if err := initTenants(); err != nil {
+		return err
+	}
+	ready.Store(true)
+	if !enableExpiredEndpoint {
+		return nil
+	}
+	expired, err := generateWithRetry(context.Background(), nowFunc().Add(-time.Hour), rsaBits)
+	if err == nil {
+		err = validateKeyPair(expired)
+	}
+	if err != nil {
+		return err
+	}
+	setExpiredKey(expired)
+	return nil
 }
 
 func main() {
+	if err := parseFlags(flag.CommandLine, os.Args[1:]); err != nil {
+		log.Fatal("Invalid configuration: ", err)
+	}
+	if genKeyOnly {
+		if err := writeGeneratedKey(os.Stdout); err != nil {
+			log.Fatal("Failed to generate key: ", err)
+		}
+		return
+	}
+	if signingAlg == "ES256" {
+		generateKeyPairFunc = generateECKeyPair
+	}
+	keygenSlots = make(chan struct{}, maxKeygen)
+	audit = newAuditLog(auditSize)
 	if err := initKeys(); err != nil {
 		log.Fatal("Failed to generate keys:", err)
 	}
-	http.HandleFunc("/.well-known/jwks.json", jwksHandler)
-	http.HandleFunc("/auth", authHandler)
-	fmt.Println("🔐 JWKS Server starting on :8080")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	if !skipSelfTest {
+		if err := selfTest(); err != nil {
+			log.Fatal("Startup self-test failed: ", err)
+		}
+	}
+	if dumpJWKSOnly {
+		if err := dumpJWKS(os.Stdout); err != nil {
+			log.Fatal("Failed to write JWKS: ", err)
+		}
+		return
+	}
+	if err := initJWE(); err != nil {
+		log.Fatal("Failed to load JWE key: ", err)
+	}
+	if err := initUsers(); err != nil {
+		log.Fatal("Failed to load users:", err)
+	}
+	al, err := openAccessLog(accessLogPath)
+	if err != nil {
+		log.Fatal("Failed to open access log: ", err)
+	}
+	accessLog = al
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if rotationInterval > 0 {
+		go rotationLoop(ctx, rotationInterval)
+	}
+	if pruneInterval > 0 {
+		go pruneLoop(ctx, pruneInterval)
+	}
+	// Only worth the CPU when something can rotate: the timer, /admin/rotate or the signature limit
+	if keyPoolSize > 0 && (rotationInterval > 0 || adminToken != "" || rotateAfterSigns > 0) {
+		keyPool = make(chan *KeyPair, keyPoolSize)
+		go keyPoolLoop(ctx, keyPool)
+	}
+	srv := newServer(listenAddr)
+	if tlsCertFile != "" {
+		certs, err := newCertReloader(tlsCertFile, tlsKeyFile)
+		if err != nil {
+			log.Fatal("Failed to load TLS certificate: ", err)
+		}
+		srv.TLSConfig = &tls.Config{GetCertificate: certs.GetCertificate, MinVersion: tls.VersionTLS12}
+	}
+	ln, err := listen(srv.Addr, os.Stdout)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := serve(ctx, srv, ln, drainTimeout); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("🔐 JWKS Server stopped")
 }
@@ -1,10 +1,24 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rsa"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	mathrand "math/rand/v2"
+	"net/http"
 	"net/http/httptest"
+	"os"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -12,21 +26,360 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
+func TestMain(m *testing.M) {
+	if err := addUser(demoUsername, demoPassword); err != nil {
+		panic(err)
+	}
+	// Tests install keys directly rather than through initKeys
+	ready.Store(true)
+	os.Exit(m.Run())
+}
+
+// Build a POST /auth request carrying JSON credentials
+func loginRequest(target, username, password string) *http.Request {
+	body, _ := json.Marshal(Credentials{Username: username, Password: password})
+	return httptest.NewRequest("POST", target, bytes.NewReader(body))
+}
+
+// Test the same seed yields the same valid key and a different seed another
+func TestGenerateKeyPairFrom_Seeded(t *testing.T) {
+	exp := time.Now().Add(time.Hour)
+	seeded := func(seed byte) io.Reader { return mathrand.NewChaCha8([32]byte{seed}) }
+	a, errA := generateKeyPairFrom(seeded(1), exp, 2048)
+	b, errB := generateKeyPairFrom(seeded(1), exp, 2048)
+	c, errC := generateKeyPairFrom(seeded(2), exp, 2048)
+	if err := errors.Join(errA, errB, errC); err != nil {
+		t.Fatal(err)
+	}
+	if a.PublicKey.N.BitLen() != 2048 || a.PrivateKey.Validate() != nil {
+		t.Errorf("Expected a valid 2048-bit key, got %d bits", a.PublicKey.N.BitLen())
+	}
+	if a.PublicKey.N.Cmp(b.PublicKey.N) != 0 {
+		t.Error("Expected identical moduli from the same seed")
+	}
+	if a.PublicKey.N.Cmp(c.PublicKey.N) == 0 {
+		t.Error("Expected different moduli from different seeds")
+	}
+}
+
 // Test key generation and JWK conversion
 func TestGenerateKeyPairAndToJWK(t *testing.T) {
-	kp, err := generateKeyPair(time.Now().Add(time.Hour))
+	kp, err := generateKeyPair(time.Now().Add(time.Hour), 2048)
 	if err != nil || kp.PrivateKey == nil || kp.PublicKey == nil {
 		t.Fatalf("Key generation failed: %v", err)
 	}
-	jwk := kp.toJWK()
+	jwk := kp.toJWK(useSig)
 	if jwk.Kty != "RSA" || jwk.Kid != kp.Kid || jwk.N == "" || jwk.E == "" {
 		t.Errorf("Invalid JWK: %+v", jwk)
 	}
 }
 
+// Test non-default RSA exponents survive JWK encoding
+func TestToJWK_ExponentRoundTrip(t *testing.T) {
+	base, _ := generateKeyPair(time.Now().Add(time.Hour), 2048)
+	for _, e := range []int{3, 65537, 1<<32 + 15, 1<<62 + 1} {
+		kp := &KeyPair{Kid: "k", PublicKey: &rsa.PublicKey{N: base.PublicKey.N, E: e}}
+		raw, err := base64.RawURLEncoding.DecodeString(kp.toJWK(useSig).E)
+		if err != nil {
+			t.Fatalf("Bad base64url for e=%d: %v", e, err)
+		}
+		if len(raw) == 0 || raw[0] == 0 {
+			t.Errorf("Exponent %d encoded with leading zero: %x", e, raw)
+		}
+		if got := new(big.Int).SetBytes(raw); got.Cmp(big.NewInt(int64(e))) != 0 {
+			t.Errorf("Expected exponent %d, got %s", e, got)
+		}
+	}
+}
+
+// Test EC key generation and JWK conversion
+func TestGenerateECKeyPairAndToJWK(t *testing.T) {
+	kp, err := generateECKeyPair(time.Now().Add(time.Hour), 0)
+	if err != nil || kp.ECKey == nil {
+		t.Fatalf("EC key generation failed: %v", err)
+	}
+	jwk := kp.toJWK(useSig)
+	if jwk.Kty != "EC" || jwk.Crv != "P-256" || jwk.Alg != "ES256" || jwk.X == "" || jwk.Y == "" {
+		t.Errorf("Invalid EC JWK: %+v", jwk)
+	}
+}
+
+// Test JWKs omit the members of the other key type
+func TestJWK_OmitsFieldsOfOtherKeyType(t *testing.T) {
+	ecKey, _ := generateECKeyPair(time.Now().Add(time.Hour), 0)
+	rsaKey, _ := generateKeyPair(time.Now().Add(time.Hour), 2048)
+	for _, tc := range []struct {
+		kp     *KeyPair
+		absent []string
+	}{
+		{ecKey, []string{"n", "e"}},
+		{rsaKey, []string{"crv", "x", "y"}},
+	} {
+		body, err := json.Marshal(tc.kp.toJWK(useSig))
+		if err != nil {
+			t.Fatalf("Marshal failed: %v", err)
+		}
+		var fields map[string]any
+		json.Unmarshal(body, &fields)
+		for _, name := range tc.absent {
+			if _, ok := fields[name]; ok {
+				t.Errorf("Expected %q absent from %s", name, body)
+			}
+		}
+	}
+}
+
+// Rebuild a verification key from a published JWK, as a relying party would
+func jwkPublicKey(jwk JWK) (crypto.PublicKey, error) {
+	var bad error
+	decode := func(s string) []byte {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil {
+			bad = fmt.Errorf("bad base64url in JWK: %w", err)
+		}
+		return b
+	}
+	switch jwk.Kty {
+	case "RSA":
+		pub := &rsa.PublicKey{N: new(big.Int).SetBytes(decode(jwk.N)), E: int(new(big.Int).SetBytes(decode(jwk.E)).Int64())}
+		return pub, bad
+	case "EC":
+		x, y := decode(jwk.X), decode(jwk.Y)
+		if bad != nil {
+			return nil, bad
+		}
+		pub, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), append(append([]byte{4}, x...), y...))
+		if err != nil {
+			return nil, fmt.Errorf("bad EC point in JWK: %w", err)
+		}
+		return pub, nil
+	}
+	return nil, fmt.Errorf("unsupported kty %q", jwk.Kty)
+}
+
+func publicKeyFromJWK(t *testing.T, jwk JWK) crypto.PublicKey {
+	t.Helper()
+	pub, err := jwkPublicKey(jwk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub
+}
+
+// Reference verifier: finds the token's kid in jwks, rebuilds the public key
+// and checks the signature, the JWK's alg and the registered claims
+func verifyToken(tokenString string, jwks JWKS) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(tok *jwt.Token) (any, error) {
+		for _, jwk := range jwks.Keys {
+			if jwk.Kid == tok.Header["kid"] && jwk.Use == useSig {
+				if tok.Method.Alg() != jwk.Alg {
+					return nil, fmt.Errorf("token alg %s does not match key alg %s", tok.Method.Alg(), jwk.Alg)
+				}
+				return jwkPublicKey(jwk)
+			}
+		}
+		return nil, fmt.Errorf("no signing key with kid %v", tok.Header["kid"])
+	})
+	return claims, err
+}
+
+// GET the JWKS from jwksHandler
+func servedJWKS(t *testing.T) JWKS {
+	t.Helper()
+	w := httptest.NewRecorder()
+	jwksHandler(w, httptest.NewRequest("GET", "/.well-known/jwks.json", nil))
+	var jwks JWKS
+	if err := json.Unmarshal(w.Body.Bytes(), &jwks); err != nil {
+		t.Fatalf("Invalid JWKS JSON: %v", err)
+	}
+	return jwks
+}
+
+// Test a freshly minted token verifies against the served JWKS
+func TestVerifyToken_Minted(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	keyRing = []*KeyPair{validKey}
+	defer func() { keyRing = nil }()
+
+	claims, err := verifyToken(mintToken(t, "/auth"), servedJWKS(t))
+	if err != nil {
+		t.Fatalf("Expected the token to verify: %v", err)
+	}
+	if claims["sub"] != "user123" || claims["iss"] != issuer {
+		t.Errorf("Unexpected claims: %v", claims)
+	}
+}
+
+// Test an expired-key token fails: its kid is unpublished, and with the key supplied the exp check rejects it
+func TestVerifyToken_ExpiredKey(t *testing.T) {
+	enableExpiredEndpoint = true
+	defer func() { enableExpiredEndpoint = false }()
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	expiredKey, _ = generateKeyPair(time.Now().Add(-time.Hour), 2048)
+	keyRing = []*KeyPair{validKey}
+	defer func() { keyRing, expiredKey = nil, nil }()
+
+	token := mintToken(t, "/auth?expired=true")
+	jwks := servedJWKS(t)
+	if _, err := verifyToken(token, jwks); err == nil {
+		t.Error("Expected an unpublished kid to fail verification")
+	}
+	jwks.Keys = append(jwks.Keys, expiredKey.toJWK(useSig))
+	if _, err := verifyToken(token, jwks); !errors.Is(err, jwt.ErrTokenExpired) {
+		t.Errorf("Expected ErrTokenExpired, got %v", err)
+	}
+}
+
+// Test leading zero bytes are stripped from big-endian integers
+func TestMinimalBytes(t *testing.T) {
+	padded := make([]byte, 4)
+	n := new(big.Int).SetBytes(big.NewInt(0x8001).FillBytes(padded))
+	if got := minimalBytes(n); !bytes.Equal(got, []byte{0x80, 0x01}) {
+		t.Errorf("Expected 8001, got %x", got)
+	}
+	if got := minimalBytes(new(big.Int)); !bytes.Equal(got, []byte{0}) {
+		t.Errorf("Expected a single zero byte for zero, got %x", got)
+	}
+}
+
+// Test the JWK modulus has no leading zero byte
+func TestToJWK_ModulusMinimal(t *testing.T) {
+	kp, _ := generateKeyPair(time.Now().Add(time.Hour), 2048)
+	n, _ := base64.RawURLEncoding.DecodeString(kp.toJWK(useSig).N)
+	if len(n) != 256 || n[0] == 0 {
+		t.Errorf("Expected a 256-byte modulus without leading zero, got %d bytes starting %x", len(n), n[0])
+	}
+}
+
+// Test a relying party can verify RS256 and ES256 tokens from a mixed JWKS
+func TestMixedJWKS_VerifyBothAlgs(t *testing.T) {
+	rsaKey, _ := generateKeyPair(time.Now().Add(time.Hour), 2048)
+	ecKey, _ := generateECKeyPair(time.Now().Add(time.Hour), 0)
+	keyRing = []*KeyPair{rsaKey, ecKey}
+	defer func() { keyRing = nil }()
+
+	w := httptest.NewRecorder()
+	jwksHandler(w, httptest.NewRequest("GET", "/.well-known/jwks.json", nil))
+	var jwks JWKS
+	json.Unmarshal(w.Body.Bytes(), &jwks)
+	if len(jwks.Keys) != 2 {
+		t.Fatalf("Expected 2 keys, got %d", len(jwks.Keys))
+	}
+
+	for _, kp := range []*KeyPair{rsaKey, ecKey} {
+		validKey = kp
+		w := httptest.NewRecorder()
+		authHandler(w, loginRequest("/auth", "user123", "password123"))
+		resp := authResponse(t, w.Body.Bytes())
+
+		if _, err := verifyToken(resp["token"], jwks); err != nil {
+			t.Errorf("%s token failed verification: %v", kp.Alg, err)
+		}
+	}
+}
+
+// GET a single JWK through the router
+func getJWK(kid string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	newRouter().ServeHTTP(w, httptest.NewRequest("GET", "/jwks/"+kid, nil))
+	return w
+}
+
+// Test a published key is served by kid
+func TestJWKHandler_Found(t *testing.T) {
+	old, _ := generateKeyPair(time.Now().Add(time.Hour), 2048)
+	validKey, _ = generateKeyPair(time.Now().Add(2*time.Hour), 2048)
+	keyRing = []*KeyPair{old, validKey}
+
+	w := getJWK(old.Kid)
+	if w.Code != 200 {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	var jwk JWK
+	envelopeData(t, w.Body.Bytes(), &jwk)
+	if want := old.toJWK(useSig); jwk.Kid != want.Kid || jwk.N != want.N || jwk.E != want.E {
+		t.Errorf("Expected the demoted key's JWK, got %+v", jwk)
+	}
+}
+
+// Test an expired key is not served
+func TestJWKHandler_Expired(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(-time.Hour), 2048)
+	keyRing = []*KeyPair{validKey}
+	assertEnvelopeError(t, getJWK(validKey.Kid), 404)
+}
+
+// Test an unknown kid is not found
+func TestJWKHandler_Unknown(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	keyRing = []*KeyPair{validKey}
+	assertEnvelopeError(t, getJWK("unknown"), 404)
+}
+
+// Test the signing method follows the key, through to signFunc and the alg header
+func TestAuthHandler_SigningMethodFromKey(t *testing.T) {
+	validKey, _ = generateECKeyPair(time.Now().Add(time.Hour), 0)
+	var got jwt.SigningMethod
+	original := signFunc
+	signFunc = func(ctx context.Context, k crypto.PrivateKey, m jwt.SigningMethod, token *jwt.Token) (string, error) {
+		got = m
+		return original(ctx, k, m, token)
+	}
+	defer func() { signFunc = original }()
+
+	w := httptest.NewRecorder()
+	authHandler(w, loginRequest("/auth", "user123", "password123"))
+	resp := authResponse(t, w.Body.Bytes())
+	token, _, err := jwt.NewParser().ParseUnverified(resp["token"], jwt.MapClaims{})
+	if err != nil || token.Header["alg"] != "ES256" {
+		t.Errorf("Expected ES256 alg header, got %v (%v)", token, err)
+	}
+	if got != jwt.SigningMethodES256 {
+		t.Errorf("Expected signFunc to receive ES256, got %v", got)
+	}
+}
+
+// Test the typ header follows -token-typ
+func TestAuthHandler_TokenTyp(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	defer func() { tokenTyp = "JWT" }()
+	for _, typ := range []string{"JWT", "at+jwt"} {
+		tokenTyp = typ
+		token, _, err := jwt.NewParser().ParseUnverified(mintToken(t, "/auth"), jwt.MapClaims{})
+		if err != nil || token.Header["typ"] != typ {
+			t.Errorf("Expected typ %s, got %v (%v)", typ, token.Header["typ"], err)
+		}
+	}
+}
+
+// Test PS256 tokens verify with the PSS method against the published JWK
+func TestPS256_VerifyAgainstJWK(t *testing.T) {
+	signingAlg = "PS256"
+	defer func() { signingAlg = "RS256" }()
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	keyRing = []*KeyPair{validKey}
+
+	w := httptest.NewRecorder()
+	jwksHandler(w, httptest.NewRequest("GET", "/.well-known/jwks.json", nil))
+	var jwks JWKS
+	json.Unmarshal(w.Body.Bytes(), &jwks)
+	if len(jwks.Keys) != 1 || jwks.Keys[0].Alg != "PS256" || jwks.Keys[0].Kty != "RSA" {
+		t.Fatalf("Expected one RSA key with alg PS256, got %+v", jwks.Keys)
+	}
+
+	w = httptest.NewRecorder()
+	authHandler(w, loginRequest("/auth", "user123", "password123"))
+	resp := authResponse(t, w.Body.Bytes())
+	if _, err := verifyToken(resp["token"], jwks); err != nil {
+		t.Errorf("PS256 token failed verification: %v", err)
+	}
+}
+
 // Test JWKS endpoint with valid key
 func TestJWKSHandler_ValidKey(t *testing.T) {
-	validKey, _ = generateKeyPair(time.Now().Add(time.Hour))
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	keyRing = []*KeyPair{validKey}
 	req := httptest.NewRequest("GET", "/.well-known/jwks.json", nil)
 	w := httptest.NewRecorder()
 	jwksHandler(w, req)
@@ -43,7 +396,8 @@ func TestJWKSHandler_ValidKey(t *testing.T) {
 
 // Test JWKS endpoint with expired key
 func TestJWKSHandler_ExpiredKey(t *testing.T) {
-	validKey, _ = generateKeyPair(time.Now().Add(-time.Hour))
+	validKey, _ = generateKeyPair(time.Now().Add(-time.Hour), 2048)
+	keyRing = []*KeyPair{validKey}
 	req := httptest.NewRequest("GET", "/.well-known/jwks.json", nil)
 	w := httptest.NewRecorder()
 	jwksHandler(w, req)
@@ -55,6 +409,111 @@ func TestJWKSHandler_ExpiredKey(t *testing.T) {
 	}
 }
 
+// Test an empty JWKS explains itself once per interval
+func TestJWKSHandler_EmptyReason(t *testing.T) {
+	var buf bytes.Buffer
+	original := logger
+	logger = slog.New(slog.NewJSONHandler(&buf, nil))
+	defer func() { logger = original }()
+	lastEmptyJWKSWarn = time.Time{}
+	validKey, keyRing = nil, nil
+	expiredKey, _ = generateKeyPair(time.Now().Add(-time.Hour), 2048)
+
+	for range 2 {
+		w := httptest.NewRecorder()
+		jwksHandler(w, httptest.NewRequest("GET", "/.well-known/jwks.json", nil))
+		if reason := w.Header().Get("X-JWKS-Empty-Reason"); !strings.Contains(reason, expiredKey.Kid) {
+			t.Errorf("Expected reason naming the expired kid, got %q", reason)
+		}
+	}
+	if n := strings.Count(buf.String(), "serving empty JWKS"); n != 1 {
+		t.Errorf("Expected exactly one rate-limited warning, got %d:\n%s", n, buf.String())
+	}
+}
+
+// Test JWKS endpoint publishes every non-expired key in the ring
+func TestJWKSHandler_MultipleKeys(t *testing.T) {
+	k1, _ := generateKeyPair(time.Now().Add(time.Hour), 2048)
+	k2, _ := generateKeyPair(time.Now().Add(2*time.Hour), 2048)
+	k3, _ := generateKeyPair(time.Now().Add(-time.Hour), 2048)
+	keyRing = []*KeyPair{k1, k2, k3}
+	defer func() { keyRing = nil }()
+
+	req := httptest.NewRequest("GET", "/.well-known/jwks.json", nil)
+	w := httptest.NewRecorder()
+	jwksHandler(w, req)
+
+	var jwks JWKS
+	if err := json.Unmarshal(w.Body.Bytes(), &jwks); err != nil {
+		t.Fatalf("Invalid JWKS JSON: %v", err)
+	}
+	if len(jwks.Keys) != 2 || jwks.Keys[0].Kid != k1.Kid || jwks.Keys[1].Kid != k2.Kid {
+		t.Errorf("Expected 2 valid keys, got %+v", jwks.Keys)
+	}
+}
+
+// Test a key within the grace period is published but cannot sign
+func TestJWKSGrace(t *testing.T) {
+	jwksGrace = 5 * time.Minute
+	defer func() { jwksGrace = 0 }()
+	validKey, _ = generateKeyPair(time.Now().Add(-time.Minute), 2048)
+	keyRing = []*KeyPair{validKey}
+
+	w := httptest.NewRecorder()
+	jwksHandler(w, httptest.NewRequest("GET", "/.well-known/jwks.json", nil))
+	var jwks JWKS
+	json.Unmarshal(w.Body.Bytes(), &jwks)
+	if len(jwks.Keys) != 1 || jwks.Keys[0].Kid != validKey.Kid {
+		t.Errorf("Expected key within grace to be published, got %+v", jwks.Keys)
+	}
+
+	w = httptest.NewRecorder()
+	authHandler(w, loginRequest("/auth", "user123", "password123"))
+	if w.Code != 500 {
+		t.Errorf("Expected 500 when signing key is past expiry, got %d", w.Code)
+	}
+
+	jwksGrace = 30 * time.Second
+	w = httptest.NewRecorder()
+	jwksHandler(w, httptest.NewRequest("GET", "/.well-known/jwks.json", nil))
+	json.Unmarshal(w.Body.Bytes(), &jwks)
+	if len(jwks.Keys) != 0 {
+		t.Errorf("Expected key past grace to be dropped, got %d keys", len(jwks.Keys))
+	}
+}
+
+// Test JWKS keys are serialized in a deterministic order
+func TestJWKSHandler_DeterministicOrder(t *testing.T) {
+	exp := time.Now().Add(time.Hour)
+	base, _ := generateKeyPair(exp, 2048)
+	later := &KeyPair{Kid: "a-later", PublicKey: base.PublicKey, ExpiresAt: exp.Add(time.Minute)}
+	keyRing = []*KeyPair{later}
+	for _, kid := range []string{"delta", "alpha", "charlie", "bravo"} {
+		keyRing = append(keyRing, &KeyPair{Kid: kid, PublicKey: base.PublicKey, ExpiresAt: exp})
+	}
+	defer func() { keyRing = nil }()
+
+	var bodies []string
+	for range 2 {
+		w := httptest.NewRecorder()
+		jwksHandler(w, httptest.NewRequest("GET", "/.well-known/jwks.json", nil))
+		bodies = append(bodies, w.Body.String())
+		keyRing[1], keyRing[3] = keyRing[3], keyRing[1]
+	}
+	if bodies[0] != bodies[1] {
+		t.Error("Expected byte-identical JWKS for the same key set")
+	}
+	var jwks JWKS
+	json.Unmarshal([]byte(bodies[0]), &jwks)
+	var kids []string
+	for _, k := range jwks.Keys {
+		kids = append(kids, k.Kid)
+	}
+	if want := []string{"alpha", "bravo", "charlie", "delta", "a-later"}; !slices.Equal(kids, want) {
+		t.Errorf("Expected kids %v, got %v", want, kids)
+	}
+}
+
 // Test JWKS wrong method
 func TestJWKSHandler_WrongMethod(t *testing.T) {
 	req := httptest.NewRequest("POST", "/.well-known/jwks.json", nil)
@@ -65,26 +524,299 @@ func TestJWKSHandler_WrongMethod(t *testing.T) {
 	}
 }
 
+// Test ?alg narrows the JWKS to keys of that algorithm
+func TestJWKSHandler_AlgFilter(t *testing.T) {
+	rsaKey, _ := generateKeyPair(time.Now().Add(time.Hour), 2048)
+	ecKey, _ := generateECKeyPair(time.Now().Add(2*time.Hour), 0)
+	keyRing = []*KeyPair{rsaKey, ecKey}
+	defer func() { keyRing = nil }()
+
+	for alg, want := range map[string]string{"RS256": rsaKey.Kid, "ES256": ecKey.Kid} {
+		w := httptest.NewRecorder()
+		jwksHandler(w, httptest.NewRequest("GET", "/.well-known/jwks.json?alg="+alg, nil))
+		var jwks JWKS
+		json.Unmarshal(w.Body.Bytes(), &jwks)
+		if w.Code != 200 || len(jwks.Keys) != 1 || jwks.Keys[0].Kid != want || jwks.Keys[0].Alg != alg {
+			t.Errorf("Expected only %s for alg %s, got %d %+v", want, alg, w.Code, jwks.Keys)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	jwksHandler(w, httptest.NewRequest("GET", "/.well-known/jwks.json?alg=HS256", nil))
+	assertEnvelopeError(t, w, 400)
+}
+
 // Test auth endpoint with valid token
 func TestAuthHandler_Valid(t *testing.T) {
-	validKey, _ = generateKeyPair(time.Now().Add(time.Hour))
-	req := httptest.NewRequest("POST", "/auth", nil)
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	req := loginRequest("/auth", "user123", "password123")
 	w := httptest.NewRecorder()
 	authHandler(w, req)
 
 	if w.Code != 200 {
 		t.Fatalf("Expected 200, got %d", w.Code)
 	}
-	var resp map[string]string
-	json.Unmarshal(w.Body.Bytes(), &resp)
+	resp := authResponse(t, w.Body.Bytes())
 	if token := resp["token"]; token == "" || len(strings.Split(token, ".")) != 3 {
 		t.Error("Invalid JWT token")
 	}
+	claims := jwt.MapClaims{}
+	jwt.ParseWithClaims(resp["token"], claims, func(*jwt.Token) (any, error) { return validKey.PublicKey, nil })
+	if claims["sub"] != "user123" {
+		t.Errorf("Expected sub user123, got %v", claims["sub"])
+	}
+}
+
+// Test auth response describes the signing key
+func TestAuthHandler_KeyInfo(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	w := httptest.NewRecorder()
+	authHandler(w, loginRequest("/auth", "user123", "password123"))
+
+	resp := authResponse(t, w.Body.Bytes())
+	if resp["kid"] != validKey.Kid {
+		t.Errorf("Expected kid %s, got %q", validKey.Kid, resp["kid"])
+	}
+	expiresAt, err := time.Parse(time.RFC3339, resp["key_expires_at"])
+	if err != nil || !expiresAt.Equal(validKey.ExpiresAt.Truncate(time.Second)) {
+		t.Errorf("Expected key_expires_at %v, got %q (%v)", validKey.ExpiresAt, resp["key_expires_at"], err)
+	}
+}
+
+// Decode the claims of the token in an /auth response without verifying it
+func mintedClaims(t *testing.T, body []byte) jwt.MapClaims {
+	t.Helper()
+	resp := authResponse(t, body)
+	return unverifiedClaims(t, resp["token"])
+}
+
+func unverifiedClaims(t *testing.T, token string) jwt.MapClaims {
+	t.Helper()
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil {
+		t.Fatalf("Bad token: %v", err)
+	}
+	return claims
+}
+
+// Stop nowFunc at at for the rest of the test
+func freezeClock(t *testing.T, at time.Time) {
+	t.Helper()
+	nowFunc = func() time.Time { return at }
+	t.Cleanup(func() { nowFunc = time.Now })
+}
+
+// Test a frozen clock yields exp of exactly the frozen time plus the default TTL
+func TestAuthHandler_FrozenClock(t *testing.T) {
+	frozen := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	freezeClock(t, frozen)
+	validKey, _ = generateKeyPair(frozen.Add(keyLifetime), 2048)
+
+	w := httptest.NewRecorder()
+	authHandler(w, loginRequest("/auth", "user123", "password123"))
+	claims := mintedClaims(t, w.Body.Bytes())
+	exp, _ := claims.GetExpirationTime()
+	iat, _ := claims.GetIssuedAt()
+	if want := frozen.Add(tokenTTL); !exp.Equal(want) || !iat.Equal(frozen) {
+		t.Errorf("Expected exp %v and iat %v, got %v and %v", want, frozen, exp, iat)
+	}
+}
+
+// Test a short requested TTL is honored
+func TestAuthHandler_ShortTTL(t *testing.T) {
+	frozen := time.Now().Truncate(time.Second)
+	freezeClock(t, frozen)
+	validKey, _ = generateKeyPair(frozen.Add(time.Hour), 2048)
+	w := httptest.NewRecorder()
+	authHandler(w, loginRequest("/auth?ttl=15m", "user123", "password123"))
+	if w.Code != 200 {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	claims := mintedClaims(t, w.Body.Bytes())
+	exp, _ := claims.GetExpirationTime()
+	if want := frozen.Add(15 * time.Minute); !exp.Equal(want) {
+		t.Errorf("Expected exp %v, got %v", want, exp)
+	}
+}
+
+// Test an over-max TTL is clamped to the server maximum
+func TestAuthHandler_TTLClamped(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(72*time.Hour), 2048)
+	w := httptest.NewRecorder()
+	authHandler(w, loginRequest("/auth?ttl=48h", "user123", "password123"))
+	claims := mintedClaims(t, w.Body.Bytes())
+	exp, _ := claims.GetExpirationTime()
+	if d := time.Until(exp.Time); d > maxTokenTTL || d < maxTokenTTL-time.Minute {
+		t.Errorf("Expected TTL clamped to %v, got %v", maxTokenTTL, d)
+	}
+}
+
+// Test a TTL beyond the key lifetime is capped at key expiry
+func TestAuthHandler_TTLCappedAtKeyExpiry(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(10*time.Minute), 2048)
+	w := httptest.NewRecorder()
+	authHandler(w, loginRequest("/auth?ttl=1h", "user123", "password123"))
+	claims := mintedClaims(t, w.Body.Bytes())
+	exp, _ := claims.GetExpirationTime()
+	if exp.Unix() != validKey.ExpiresAt.Unix() {
+		t.Errorf("Expected exp %d, got %d", validKey.ExpiresAt.Unix(), exp.Unix())
+	}
+}
+
+// Test the default TTL is also clamped to the key expiry
+func TestAuthHandler_DefaultTTLCappedAtKeyExpiry(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(10*time.Minute), 2048)
+	w := httptest.NewRecorder()
+	authHandler(w, loginRequest("/auth", "user123", "password123"))
+	exp, _ := mintedClaims(t, w.Body.Bytes()).GetExpirationTime()
+	if exp.Unix() != validKey.ExpiresAt.Unix() {
+		t.Errorf("Expected exp %d, got %d", validKey.ExpiresAt.Unix(), exp.Unix())
+	}
+}
+
+// Test issueToken enforces the key expiry whatever exp it is given
+func TestIssueToken_ClampsToKeyExpiry(t *testing.T) {
+	kp, _ := generateKeyPair(time.Now().Add(10*time.Minute), 2048)
+	token, err := issueToken(context.Background(), kp, "user123", nil, time.Now().Add(time.Hour).Unix())
+	if err != nil {
+		t.Fatalf("issueToken failed: %v", err)
+	}
+	exp, _ := unverifiedClaims(t, token).GetExpirationTime()
+	if exp.Unix() != kp.ExpiresAt.Unix() {
+		t.Errorf("Expected exp %d, got %d", kp.ExpiresAt.Unix(), exp.Unix())
+	}
+}
+
+// Test a malformed TTL is rejected
+func TestAuthHandler_BadTTL(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	w := httptest.NewRecorder()
+	authHandler(w, loginRequest("/auth?ttl=soon", "user123", "password123"))
+	if w.Code != 400 {
+		t.Errorf("Expected 400, got %d", w.Code)
+	}
+}
+
+// Test minted tokens carry iss, aud and nbf
+func TestAuthHandler_IssuerAudience(t *testing.T) {
+	originalIssuer, originalAudience := issuer, audience
+	issuer, audience = "https://issuer.example", splitList("api-a, api-b,")
+	defer func() { issuer, audience = originalIssuer, originalAudience }()
+
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	w := httptest.NewRecorder()
+	authHandler(w, loginRequest("/auth", "user123", "password123"))
+	claims := mintedClaims(t, w.Body.Bytes())
+
+	if iss, _ := claims.GetIssuer(); iss != "https://issuer.example" {
+		t.Errorf("Unexpected iss %q", iss)
+	}
+	if aud, _ := claims.GetAudience(); len(aud) != 2 || aud[0] != "api-a" || aud[1] != "api-b" {
+		t.Errorf("Unexpected aud %v", aud)
+	}
+	if _, ok := claims["aud"].([]any); !ok {
+		t.Errorf("Expected aud to be a JSON array, got %T", claims["aud"])
+	}
+	nbf, _ := claims.GetNotBefore()
+	iat, _ := claims.GetIssuedAt()
+	if nbf == nil || iat == nil || !nbf.Equal(iat.Time) {
+		t.Errorf("Expected nbf equal to iat, got nbf=%v iat=%v", nbf, iat)
+	}
+}
+
+// Test nbf is backdated by the configured skew
+func TestAuthHandler_NbfSkew(t *testing.T) {
+	nbfSkew = 30 * time.Second
+	defer func() { nbfSkew = 0 }()
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	w := httptest.NewRecorder()
+	authHandler(w, loginRequest("/auth", "user123", "password123"))
+	claims := mintedClaims(t, w.Body.Bytes())
+
+	nbf, _ := claims.GetNotBefore()
+	iat, _ := claims.GetIssuedAt()
+	if d := iat.Sub(nbf.Time); d != 30*time.Second {
+		t.Errorf("Expected nbf 30s before iat, got %v", d)
+	}
+}
+
+// Test the skew never pushes nbf past exp
+func TestIssueToken_NbfNotAfterExp(t *testing.T) {
+	kp, _ := generateKeyPair(time.Now().Add(time.Hour), 2048)
+	exp := time.Now().Add(-time.Hour).Unix()
+	token, _ := issueToken(context.Background(), kp, "user123", nil, exp)
+	if nbf, _ := unverifiedClaims(t, token).GetNotBefore(); nbf.Unix() > exp {
+		t.Errorf("Expected nbf <= exp, got nbf=%d exp=%d", nbf.Unix(), exp)
+	}
+}
+
+// Test auth endpoint rejects a wrong password
+func TestAuthHandler_WrongPassword(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	w := httptest.NewRecorder()
+	authHandler(w, loginRequest("/auth", "user123", "nope"))
+	if w.Code != 401 {
+		t.Errorf("Expected 401, got %d", w.Code)
+	}
+}
+
+// Test auth endpoint rejects an unknown user
+func TestAuthHandler_UnknownUser(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	w := httptest.NewRecorder()
+	authHandler(w, loginRequest("/auth", "mallory", "password123"))
+	if w.Code != 401 {
+		t.Errorf("Expected 401, got %d", w.Code)
+	}
+}
+
+// Test GET /auth issues a token for valid Basic credentials
+func TestAuthHandler_BasicAuth(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	req := httptest.NewRequest("GET", "/auth", nil)
+	req.SetBasicAuth("user123", "password123")
+	w := httptest.NewRecorder()
+	authHandler(w, req)
+	if w.Code != 200 {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if sub := mintedClaims(t, w.Body.Bytes())["sub"]; sub != "user123" {
+		t.Errorf("Expected sub user123, got %v", sub)
+	}
+}
+
+// Test invalid Basic credentials get 401 with a Basic challenge
+func TestAuthHandler_BasicAuthInvalid(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	for _, method := range []string{"GET", "POST"} {
+		req := httptest.NewRequest(method, "/auth", nil)
+		req.SetBasicAuth("user123", "nope")
+		w := httptest.NewRecorder()
+		authHandler(w, req)
+		assertEnvelopeError(t, w, 401)
+		if !strings.HasPrefix(w.Header().Get("WWW-Authenticate"), "Basic ") {
+			t.Errorf("%s: expected a Basic challenge, got %q", method, w.Header().Get("WWW-Authenticate"))
+		}
+	}
+}
+
+// Test a JSON body takes precedence over Basic credentials
+func TestAuthHandler_BodyBeatsBasicAuth(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	req := loginRequest("/auth", "user123", "nope")
+	req.SetBasicAuth("user123", "password123")
+	w := httptest.NewRecorder()
+	authHandler(w, req)
+	if w.Code != 401 {
+		t.Errorf("Expected the body's wrong password to win with 401, got %d", w.Code)
+	}
 }
 
 // Test auth endpoint with expired token
 func TestAuthHandler_Expired(t *testing.T) {
-	expiredKey, _ = generateKeyPair(time.Now().Add(-time.Hour))
+	enableExpiredEndpoint = true
+	defer func() { enableExpiredEndpoint = false }()
+	expiredKey, _ = generateKeyPair(time.Now().Add(-time.Hour), 2048)
 	req := httptest.NewRequest("POST", "/auth?expired=true", nil)
 	w := httptest.NewRecorder()
 	authHandler(w, req)
@@ -92,13 +824,40 @@ func TestAuthHandler_Expired(t *testing.T) {
 	if w.Code != 200 {
 		t.Fatalf("Expected 200, got %d", w.Code)
 	}
-	var resp map[string]string
-	json.Unmarshal(w.Body.Bytes(), &resp)
+	resp := authResponse(t, w.Body.Bytes())
 	if resp["token"] == "" {
 		t.Error("Expected token in response")
 	}
 }
 
+// Test the expired path is a 404 unless enabled
+func TestAuthHandler_ExpiredDisabled(t *testing.T) {
+	expiredKey, _ = generateKeyPair(time.Now().Add(-time.Hour), 2048)
+	w := httptest.NewRecorder()
+	authHandler(w, httptest.NewRequest("POST", "/auth?expired=true", nil))
+	assertEnvelopeError(t, w, 404)
+}
+
+// Test expired is parsed as a boolean: false signs with the active key, garbage is a 400
+func TestAuthHandler_ExpiredParam(t *testing.T) {
+	enableExpiredEndpoint = true
+	defer func() { enableExpiredEndpoint = false }()
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	expiredKey, _ = generateKeyPair(time.Now().Add(-time.Hour), 2048)
+
+	for value, want := range map[string]string{"true": expiredKey.Kid, "false": validKey.Kid, "0": validKey.Kid} {
+		w := httptest.NewRecorder()
+		authHandler(w, loginRequest("/auth?expired="+value, "user123", "password123"))
+		if kid := authResponse(t, w.Body.Bytes())["kid"]; kid != want {
+			t.Errorf("expired=%s: expected kid %s, got %s", value, want, kid)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	authHandler(w, loginRequest("/auth?expired=maybe", "user123", "password123"))
+	assertEnvelopeError(t, w, 400)
+}
+
 // Test auth endpoint with no keys
 func TestAuthHandler_NoKeys(t *testing.T) {
 	validKey, expiredKey = nil, nil
@@ -112,7 +871,7 @@ func TestAuthHandler_NoKeys(t *testing.T) {
 
 // Test auth wrong method
 func TestAuthHandler_WrongMethod(t *testing.T) {
-	req := httptest.NewRequest("GET", "/auth", nil)
+	req := httptest.NewRequest("DELETE", "/auth", nil)
 	w := httptest.NewRecorder()
 	authHandler(w, req)
 	if w.Code != 405 {
@@ -120,16 +879,55 @@ func TestAuthHandler_WrongMethod(t *testing.T) {
 	}
 }
 
+// Test health endpoint with a valid signing key
+func TestHealthHandler_OK(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	keyRing = []*KeyPair{validKey}
+	w := httptest.NewRecorder()
+	healthHandler(w, httptest.NewRequest("GET", "/healthz", nil))
+
+	if w.Code != 200 {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	var resp struct {
+		Status string `json:"status"`
+		Keys   int    `json:"keys"`
+	}
+	envelopeData(t, w.Body.Bytes(), &resp)
+	if resp.Status != "ok" || resp.Keys != 1 {
+		t.Errorf("Unexpected health response: %+v", resp)
+	}
+}
+
+// Test health endpoint with no signing key
+func TestHealthHandler_NoKeys(t *testing.T) {
+	validKey, keyRing = nil, nil
+	w := httptest.NewRecorder()
+	healthHandler(w, httptest.NewRequest("GET", "/healthz", nil))
+	if w.Code != 503 {
+		t.Errorf("Expected 503, got %d", w.Code)
+	}
+}
+
+// Test health wrong method
+func TestHealthHandler_WrongMethod(t *testing.T) {
+	w := httptest.NewRecorder()
+	healthHandler(w, httptest.NewRequest("POST", "/healthz", nil))
+	if w.Code != 405 {
+		t.Errorf("Expected 405, got %d", w.Code)
+	}
+}
+
 // Test signing failure simulation
 func TestAuthHandler_SignFailure(t *testing.T) {
-	validKey, _ = generateKeyPair(time.Now().Add(time.Hour))
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
 	originalSign := signFunc
-	signFunc = func(*rsa.PrivateKey, jwt.SigningMethod, *jwt.Token) (string, error) {
+	signFunc = func(context.Context, crypto.PrivateKey, jwt.SigningMethod, *jwt.Token) (string, error) {
 		return "", errors.New("sign failure")
 	}
 	defer func() { signFunc = originalSign }()
 
-	req := httptest.NewRequest("POST", "/auth", nil)
+	req := loginRequest("/auth", "user123", "password123")
 	w := httptest.NewRecorder()
 	authHandler(w, req)
 	if w.Code != 500 {
@@ -137,10 +935,52 @@ func TestAuthHandler_SignFailure(t *testing.T) {
 	}
 }
 
+// Test a claims validator can veto a token with 403 and its reason
+func TestAuthHandler_ClaimsRejected(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	original := validateClaims
+	validateClaims = func(claims jwt.MapClaims) error {
+		if claims["sub"] == "user123" {
+			return errors.New("subject user123 is not allowed")
+		}
+		return nil
+	}
+	defer func() { validateClaims = original }()
+
+	w := httptest.NewRecorder()
+	authHandler(w, loginRequest("/auth", "user123", "password123"))
+	if p := assertEnvelopeError(t, w, 403); p.Code != "claims_rejected" || !strings.Contains(p.Detail, "user123 is not allowed") {
+		t.Errorf("Expected claims_rejected with the reason, got %q %q", p.Code, p.Detail)
+	}
+}
+
+// Test a signer that outlives the deadline yields 504
+func TestAuthHandler_SignTimeout(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	originalSign, originalTimeout := signFunc, signTimeout
+	signTimeout = 50 * time.Millisecond
+	signFunc = func(ctx context.Context, _ crypto.PrivateKey, _ jwt.SigningMethod, _ *jwt.Token) (string, error) {
+		time.Sleep(5 * time.Second)
+		return "too-late", nil
+	}
+	defer func() { signFunc, signTimeout = originalSign, originalTimeout }()
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	authHandler(w, loginRequest("/auth", "user123", "password123"))
+	if w.Code != 504 {
+		t.Errorf("Expected 504, got %d", w.Code)
+	}
+	// Generous bound: bcrypt alone is slow under -race
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Handler waited %v for a slow signer", elapsed)
+	}
+}
+
 // Test key generation failure
 func TestInitKeysFailure(t *testing.T) {
 	original := generateKeyPairFunc
-	generateKeyPairFunc = func(time.Time) (*KeyPair, error) {
+	generateKeyPairFunc = func(time.Time, int) (*KeyPair, error) {
 		return nil, errors.New("generation failure")
 	}
 	defer func() { generateKeyPairFunc = original }()
@@ -148,4 +988,53 @@ func TestInitKeysFailure(t *testing.T) {
 	if err := initKeys(); err == nil {
 		t.Error("Expected error from initKeys")
 	}
-}
\ No newline at end of file
+}
+
+// Test configurable RSA key size
+func TestGenerateKeyPair_KeySize(t *testing.T) {
+	kp, err := generateKeyPair(time.Now().Add(time.Hour), 3072)
+	if err != nil {
+		t.Fatalf("Key generation failed: %v", err)
+	}
+	if bits := kp.PrivateKey.N.BitLen(); bits < 3072 {
+		t.Errorf("Expected at least 3072 bits, got %d", bits)
+	}
+}
+
+// Test weak RSA key sizes are rejected at startup
+func TestInitKeys_RejectsWeakKeySize(t *testing.T) {
+	original := rsaBits
+	rsaBits = 1024
+	defer func() { rsaBits = original }()
+
+	if err := initKeys(); err == nil {
+		t.Error("Expected error for 1024-bit keys")
+	}
+}
+// Test kid lookup finds the active key
+func TestFindKeyByKid_Active(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	keyRing = nil
+	if kp, ok := findKeyByKid(validKey.Kid); !ok || kp != validKey {
+		t.Error("Expected to find the active signing key")
+	}
+}
+
+// Test kid lookup finds a demoted key still in the ring
+func TestFindKeyByKid_Demoted(t *testing.T) {
+	old, _ := generateKeyPair(time.Now().Add(time.Hour), 2048)
+	validKey, _ = generateKeyPair(time.Now().Add(2*time.Hour), 2048)
+	keyRing = []*KeyPair{old, validKey}
+	if kp, ok := findKeyByKid(old.Kid); !ok || kp != old {
+		t.Error("Expected to find the demoted key")
+	}
+}
+
+// Test kid lookup misses an unknown kid
+func TestFindKeyByKid_Miss(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	keyRing = []*KeyPair{validKey}
+	if kp, ok := findKeyByKid("unknown"); ok || kp != nil {
+		t.Errorf("Expected a miss, got %v", kp)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Prometheus metrics exported on /metrics
+var (
+	tokensIssued = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "jwks_tokens_issued_total",
+		Help: "Number of tokens issued by /auth.",
+	})
+	jwksRequests = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "jwks_requests_total",
+		Help: "Number of JWKS documents served.",
+	})
+	signingFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "jwks_signing_failures_total",
+		Help: "Number of token signing failures.",
+	})
+	authLatency = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "jwks_auth_duration_seconds",
+		Help:    "Latency of /auth requests.",
+		Buckets: prometheus.DefBuckets,
+	})
+	validKeysGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "jwks_valid_keys",
+		Help: "Number of currently-valid keys in the key ring.",
+	})
+)
+
+// Replaces the published key ring and refreshes the valid-key gauge
+func setKeyRing(ring []*KeyPair) {
+	keyMu.Lock()
+	defer keyMu.Unlock()
+	setKeyRingLocked(ring)
+}
+
+// setKeyRing for callers already holding keyMu
+func setKeyRingLocked(ring []*KeyPair) {
+	keyRing = ring
+	now, valid := nowFunc(), 0
+	for _, kp := range ring {
+		if kp != nil && now.Before(kp.ExpiresAt) {
+			valid++
+		}
+	}
+	validKeysGauge.Set(float64(valid))
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bufio"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Scrape /metrics and return the value of a single unlabelled series
+func scrapeMetric(t *testing.T, name string) float64 {
+	t.Helper()
+	w := httptest.NewRecorder()
+	newRouter().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	sc := bufio.NewScanner(w.Body)
+	for sc.Scan() {
+		if fields := strings.Fields(sc.Text()); len(fields) == 2 && fields[0] == name {
+			v, _ := strconv.ParseFloat(fields[1], 64)
+			return v
+		}
+	}
+	t.Fatalf("Metric %s not found", name)
+	return 0
+}
+
+// Test issuing a token increments the exported counter
+func TestMetrics_TokensIssued(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	before := scrapeMetric(t, "jwks_tokens_issued_total")
+
+	w := httptest.NewRecorder()
+	newRouter().ServeHTTP(w, loginRequest("/auth", "user123", "password123"))
+	if w.Code != 200 {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	if after := scrapeMetric(t, "jwks_tokens_issued_total"); after != before+1 {
+		t.Errorf("Expected counter %v, got %v", before+1, after)
+	}
+}
+
+// Test the valid-key gauge follows the key ring
+func TestMetrics_ValidKeysGauge(t *testing.T) {
+	k1, _ := generateKeyPair(time.Now().Add(time.Hour), 2048)
+	k2, _ := generateKeyPair(time.Now().Add(-time.Hour), 2048)
+	setKeyRing([]*KeyPair{k1, k2})
+	defer setKeyRing(nil)
+	if v := scrapeMetric(t, "jwks_valid_keys"); v != 1 {
+		t.Errorf("Expected 1 valid key, got %v", v)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// RFC 7517 media type for a JWK Set
+const jwkSetMediaType = "application/jwk-set+json"
+
+// Picks the JWKS response content type from the Accept header. The JWK Set
+// type is used only when the client prefers it over plain JSON; wildcards
+// and a missing header keep application/json.
+func jwksContentType(r *http.Request) string {
+	var jwkQ, jsonQ float64 = -1, -1
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		switch mediaType {
+		case jwkSetMediaType:
+			jwkQ = max(jwkQ, q)
+		case "application/json", "application/*", "*/*":
+			jsonQ = max(jsonQ, q)
+		}
+	}
+	if jwkQ > 0 && jwkQ > jsonQ {
+		return jwkSetMediaType
+	}
+	return "application/json"
+}
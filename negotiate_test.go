@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Test the JWKS content type follows the Accept header
+func TestJWKSHandler_ContentNegotiation(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	keyRing = []*KeyPair{validKey}
+	tests := []struct {
+		accept, want string
+	}{
+		{"", "application/json"},
+		{"*/*", "application/json"},
+		{"application/json", "application/json"},
+		{"application/jwk-set+json", "application/jwk-set+json"},
+		{"application/jwk-set+json, application/json;q=0.5", "application/jwk-set+json"},
+		{"application/json, application/jwk-set+json;q=0.5", "application/json"},
+		{"application/jwk-set+json;q=0, */*", "application/json"},
+		{"text/html", "application/json"},
+	}
+	for _, tc := range tests {
+		req := httptest.NewRequest("GET", "/.well-known/jwks.json", nil)
+		if tc.accept != "" {
+			req.Header.Set("Accept", tc.accept)
+		}
+		w := httptest.NewRecorder()
+		jwksHandler(w, req)
+		if got := w.Header().Get("Content-Type"); got != tc.want {
+			t.Errorf("Accept %q: expected %q, got %q", tc.accept, tc.want, got)
+		}
+		if w.Header().Get("Vary") != "Accept" {
+			t.Errorf("Accept %q: expected Vary: Accept", tc.accept)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Path to a PEM-encoded RSA private key to use instead of a generated one
+var keyFile string
+
+// Print a freshly generated key as PEM and exit instead of serving
+var genKeyOnly bool
+
+// PEM block type wrapping encryptPrivateKey output
+const encryptedKeyBlock = "JWKS ENCRYPTED PRIVATE KEY"
+
+// Parses a PKCS#1 or PKCS#8 RSA private key from PEM, decrypting an
+// encryptedKeyBlock with keyPassphrase
+func parseRSAPrivateKeyPEM(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	switch block.Type {
+	case encryptedKeyBlock:
+		if len(keyPassphrase) == 0 {
+			return nil, fmt.Errorf("encrypted key needs a passphrase; set -key-passphrase or $%s", passphraseEnv)
+		}
+		return decryptPrivateKey(block.Bytes, keyPassphrase)
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T, want RSA", key)
+		}
+		return rsaKey, nil
+	}
+	return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+}
+
+// Loads the key at path as a signing key pair expiring at expiresAt
+func loadKeyPairFile(path string, expiresAt time.Time) (*KeyPair, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := parseRSAPrivateKeyPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("load key file %s: %w", path, err)
+	}
+	return finalizeKeyPair(&KeyPair{Alg: rsaAlg(), PrivateKey: key, PublicKey: &key.PublicKey, ExpiresAt: expiresAt})
+}
+
+// Generates an RSA key and writes its would-be kid, the private key and the
+// SPKI public key as PEM, ready for use with -key-file. The private key is
+// PKCS#8, or an encryptedKeyBlock when keyPassphrase is set.
+func writeGeneratedKey(out io.Writer) error {
+	kp, err := generateKeyPair(nowFunc().Add(keyLifetime), rsaBits)
+	if err != nil {
+		return err
+	}
+	privType := "PRIVATE KEY"
+	priv, err := x509.MarshalPKCS8PrivateKey(kp.PrivateKey)
+	if len(keyPassphrase) > 0 {
+		privType = encryptedKeyBlock
+		priv, err = encryptPrivateKey(kp.PrivateKey, keyPassphrase)
+	}
+	if err != nil {
+		return err
+	}
+	pub, err := x509.MarshalPKIXPublicKey(kp.PublicKey)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "# kid: %s\n", kp.Kid)
+	if err := pem.Encode(out, &pem.Block{Type: privType, Bytes: priv}); err != nil {
+		return err
+	}
+	return pem.Encode(out, &pem.Block{Type: "PUBLIC KEY", Bytes: pub})
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Write PEM data to a temp key file and point -key-file at it
+func useKeyFile(t *testing.T, blockType string, der []byte) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	keyFile = path
+	t.Cleanup(func() { keyFile = "" })
+}
+
+// Test loading a PKCS#1 key file
+func TestInitKeys_PKCS1KeyFile(t *testing.T) {
+	priv, _ := rsa.GenerateKey(rand.Reader, 2048)
+	useKeyFile(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(priv))
+	if err := initKeys(); err != nil {
+		t.Fatalf("initKeys failed: %v", err)
+	}
+	if !validKey.PrivateKey.Equal(priv) || validKey.Kid == "" {
+		t.Error("Expected validKey to be the loaded key")
+	}
+}
+
+// Test loading a PKCS#8 key file
+func TestInitKeys_PKCS8KeyFile(t *testing.T) {
+	priv, _ := rsa.GenerateKey(rand.Reader, 2048)
+	der, _ := x509.MarshalPKCS8PrivateKey(priv)
+	useKeyFile(t, "PRIVATE KEY", der)
+	if err := initKeys(); err != nil {
+		t.Fatalf("initKeys failed: %v", err)
+	}
+	if !validKey.PublicKey.Equal(&priv.PublicKey) {
+		t.Error("Expected public key derived from the loaded key")
+	}
+}
+
+// Test malformed and non-RSA key files fail startup
+func TestInitKeys_InvalidKeyFile(t *testing.T) {
+	useKeyFile(t, "RSA PRIVATE KEY", []byte("garbage"))
+	if err := initKeys(); err == nil {
+		t.Error("Expected error for malformed key")
+	}
+
+	path := filepath.Join(t.TempDir(), "notpem.txt")
+	os.WriteFile(path, []byte("not a pem file"), 0600)
+	keyFile = path
+	if err := initKeys(); err == nil {
+		t.Error("Expected error for non-PEM file")
+	}
+
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, _ := x509.MarshalPKCS8PrivateKey(ecKey)
+	useKeyFile(t, "PRIVATE KEY", der)
+	if err := initKeys(); err == nil {
+		t.Error("Expected error for non-RSA key")
+	}
+}
+
+// Test -gen-key output parses back into a usable key file
+func TestWriteGeneratedKey(t *testing.T) {
+	var out bytes.Buffer
+	if err := writeGeneratedKey(&out); err != nil {
+		t.Fatalf("writeGeneratedKey failed: %v", err)
+	}
+	rest := out.Bytes()
+	if !bytes.HasPrefix(rest, []byte("# kid: ")) {
+		t.Errorf("Expected output to start with the kid, got %q", rest[:20])
+	}
+	priv, err := parseRSAPrivateKeyPEM(rest)
+	if err != nil {
+		t.Fatalf("Emitted private key does not parse: %v", err)
+	}
+	if err := priv.Validate(); err != nil {
+		t.Fatalf("Emitted private key is invalid: %v", err)
+	}
+	_, after := pem.Decode(rest)
+	block, _ := pem.Decode(after)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		t.Fatal("Expected a PUBLIC KEY block after the private key")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil || !priv.PublicKey.Equal(pub) {
+		t.Errorf("Public key does not match private key: %v", err)
+	}
+}
+
+// Test an encrypted key file loads with the passphrase and fails without it or with a wrong one
+func TestInitKeys_EncryptedKeyFile(t *testing.T) {
+	priv, _ := rsa.GenerateKey(rand.Reader, 2048)
+	data, err := encryptPrivateKey(priv, []byte("correct horse"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	useKeyFile(t, encryptedKeyBlock, data)
+	defer func() { keyPassphrase = nil }()
+
+	for _, pass := range []string{"", "battery staple"} {
+		keyPassphrase = []byte(pass)
+		if err := initKeys(); err == nil {
+			t.Errorf("Expected an error with passphrase %q", pass)
+		}
+	}
+	keyPassphrase = []byte("correct horse")
+	if err := initKeys(); err != nil {
+		t.Fatalf("initKeys failed: %v", err)
+	}
+	if !validKey.PrivateKey.Equal(priv) {
+		t.Error("Expected validKey to be the decrypted key")
+	}
+}
+
+// Test -gen-key encrypts its private key when a passphrase is set
+func TestWriteGeneratedKey_Encrypted(t *testing.T) {
+	keyPassphrase = []byte("correct horse")
+	defer func() { keyPassphrase = nil }()
+	var out bytes.Buffer
+	if err := writeGeneratedKey(&out); err != nil {
+		t.Fatalf("writeGeneratedKey failed: %v", err)
+	}
+	if !bytes.Contains(out.Bytes(), []byte("BEGIN "+encryptedKeyBlock)) {
+		t.Fatalf("Expected an encrypted private key block, got %s", out.Bytes())
+	}
+	if _, err := parseRSAPrivateKeyPEM(out.Bytes()); err != nil {
+		t.Errorf("Encrypted key does not load back: %v", err)
+	}
+}
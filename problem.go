@@ -0,0 +1,23 @@
+package main
+
+// Envelope error body, shaped like RFC 7807 problem details
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	// Machine-readable error code for alerting, set on server-side failures
+	Code string `json:"code,omitempty"`
+}
+
+// Builds a problem body; it doubles as an error so it can be passed to respondError
+func newProblem(status int, code, title, detail string) *Problem {
+	return &Problem{Type: "about:blank", Title: title, Status: status, Detail: detail, Code: code}
+}
+
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Detail
+	}
+	return p.Title
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Test 405 responses from the standards documents still use the envelope
+func TestProblem_MethodNotAllowed(t *testing.T) {
+	w := httptest.NewRecorder()
+	jwkHandler(w, httptest.NewRequest("POST", "/jwks/abc", nil))
+	assertEnvelopeError(t, w, 405)
+
+	w = httptest.NewRecorder()
+	healthHandler(w, httptest.NewRequest("POST", "/healthz", nil))
+	assertEnvelopeError(t, w, 405)
+}
+
+// Test signing failures carry a detail and code
+func TestProblem_SignFailure(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	originalSign := signFunc
+	signFunc = func(context.Context, crypto.PrivateKey, jwt.SigningMethod, *jwt.Token) (string, error) {
+		return "", errors.New("sign failure")
+	}
+	defer func() { signFunc = originalSign }()
+
+	w := httptest.NewRecorder()
+	authHandler(w, loginRequest("/auth", "user123", "password123"))
+	if p := assertEnvelopeError(t, w, 500); p.Detail != "Failed to sign token" || p.Code != "signing_failed" {
+		t.Errorf("Unexpected detail/code: %q %q", p.Detail, p.Code)
+	}
+}
+
+// Test a missing signing key is reported with its own code
+func TestProblem_NoSigningKey(t *testing.T) {
+	validKey = nil
+	w := httptest.NewRecorder()
+	authHandler(w, loginRequest("/auth", "user123", "password123"))
+	if p := assertEnvelopeError(t, w, 500); p.Code != "no_signing_key" {
+		t.Errorf("Expected code no_signing_key, got %q", p.Code)
+	}
+}
+
+// Test client errors carry no code
+func TestProblem_NoCodeForClientErrors(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	w := httptest.NewRecorder()
+	authHandler(w, loginRequest("/auth", "user123", "wrong"))
+	if p := assertEnvelopeError(t, w, 401); p.Code != "" {
+		t.Errorf("Expected no code, got %q", p.Code)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Tokens /auth may issue per subject within quotaWindow (0 disables the quota)
+var (
+	quotaLimit  int
+	quotaWindow = time.Hour
+)
+
+// Rolling per-subject count of recent issuances
+type issuanceQuota struct {
+	mu        sync.Mutex
+	now       func() time.Time
+	issued    map[string][]time.Time
+	lastSweep time.Time
+}
+
+var quotas = newIssuanceQuota(func() time.Time { return nowFunc() })
+
+func newIssuanceQuota(now func() time.Time) *issuanceQuota {
+	return &issuanceQuota{now: now, issued: map[string][]time.Time{}}
+}
+
+// Records an issuance for sub if it is under limit within the trailing window.
+// Otherwise reports how long until the oldest counted issuance ages out.
+func (q *issuanceQuota) allow(sub string, limit int, window time.Duration) (bool, time.Duration) {
+	if limit <= 0 {
+		return true, 0
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := q.now()
+	q.sweepLocked(now, window)
+	recent := unexpired(q.issued[sub], now, window)
+	if len(recent) >= limit {
+		q.issued[sub] = recent
+		return false, recent[0].Add(window).Sub(now)
+	}
+	q.issued[sub] = append(recent, now)
+	return true, 0
+}
+
+// Issuances still inside window at now; they are kept oldest first
+func unexpired(issued []time.Time, now time.Time, window time.Duration) []time.Time {
+	for len(issued) > 0 && !now.Before(issued[0].Add(window)) {
+		issued = issued[1:]
+	}
+	return issued
+}
+
+// Once per window, forgets subjects with no issuance left inside it, so
+// subjects that never come back do not grow the map without bound
+func (q *issuanceQuota) sweepLocked(now time.Time, window time.Duration) {
+	if now.Sub(q.lastSweep) < window {
+		return
+	}
+	q.lastSweep = now
+	for sub, issued := range q.issued {
+		if recent := unexpired(issued, now, window); len(recent) == 0 {
+			delete(q.issued, sub)
+		} else {
+			q.issued[sub] = recent
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Test a subject over quota gets 429 while others are unaffected
+func TestAuthHandler_Quota(t *testing.T) {
+	quotaLimit, quotaWindow = 2, time.Minute
+	clock := time.Now()
+	quotas = newIssuanceQuota(func() time.Time { return clock })
+	defer func() { quotaLimit, quotas = 0, newIssuanceQuota(time.Now) }()
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	addUser("alice", "wonderland")
+
+	login := func(user, pass string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		authHandler(w, loginRequest("/auth", user, pass))
+		return w
+	}
+	for i := range 2 {
+		if w := login("user123", "password123"); w.Code != 200 {
+			t.Fatalf("Request %d: expected 200, got %d", i, w.Code)
+		}
+	}
+	w := login("user123", "password123")
+	assertEnvelopeError(t, w, 429)
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("Expected Retry-After header")
+	}
+	if w := login("alice", "wonderland"); w.Code != 200 {
+		t.Errorf("Expected other subject to be unaffected, got %d", w.Code)
+	}
+
+	clock = clock.Add(time.Minute)
+	if w := login("user123", "password123"); w.Code != 200 {
+		t.Errorf("Expected quota to reset after the window, got %d", w.Code)
+	}
+}
+
+// Test the window rolls rather than resetting all at once
+func TestIssuanceQuota_Rolling(t *testing.T) {
+	clock := time.Now()
+	q := newIssuanceQuota(func() time.Time { return clock })
+	q.allow("bob", 2, time.Minute)
+	clock = clock.Add(30 * time.Second)
+	q.allow("bob", 2, time.Minute)
+	clock = clock.Add(31 * time.Second)
+	if ok, _ := q.allow("bob", 2, time.Minute); !ok {
+		t.Error("Expected the first issuance to have aged out")
+	}
+	if ok, retry := q.allow("bob", 2, time.Minute); ok || retry != 29*time.Second {
+		t.Errorf("Expected denial with 29s retry, got %v %v", ok, retry)
+	}
+}
+
+// Test subjects with nothing left in the window are forgotten
+func TestIssuanceQuota_PrunesIdleSubjects(t *testing.T) {
+	clock := time.Now()
+	q := newIssuanceQuota(func() time.Time { return clock })
+	for i := range 100 {
+		q.allow(fmt.Sprintf("user%d", i), 1, time.Minute)
+	}
+	clock = clock.Add(time.Minute)
+	q.allow("alice", 1, time.Minute)
+	if len(q.issued) != 1 {
+		t.Errorf("Expected only the active subject to remain, got %d", len(q.issued))
+	}
+}
@@ -0,0 +1,13 @@
+package main
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// Set once initKeys has stored the first signing key. Until then /healthz
+// reports "not ready" and /auth refuses with 503, so a boot that generates
+// keys in the background never serves an empty JWKS or unsigned tokens.
+var ready atomic.Bool
+
+var errWarmingUp = errors.New("warming up")
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Test /healthz and /auth answer 503 until the first key is ready
+func TestReadiness_NotReady(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	ready.Store(false)
+	defer ready.Store(true)
+
+	w := httptest.NewRecorder()
+	healthHandler(w, httptest.NewRequest("GET", "/healthz", nil))
+	var health map[string]any
+	envelopeData(t, w.Body.Bytes(), &health)
+	if w.Code != 503 || health["status"] != "not ready" {
+		t.Errorf("Expected 503 not ready, got %d %v", w.Code, health)
+	}
+
+	w = httptest.NewRecorder()
+	authHandler(w, loginRequest("/auth", "user123", "password123"))
+	if p := assertEnvelopeError(t, w, 503); p.Detail != "warming up" {
+		t.Errorf("Expected warming up detail, got %q", p.Detail)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("Expected Retry-After on a warming-up response")
+	}
+}
+
+// Test initKeys marks the server ready once the first key is stored
+func TestReadiness_SetByInitKeys(t *testing.T) {
+	validKey, keyRing = nil, nil
+	ready.Store(false)
+	defer ready.Store(true)
+	if err := initKeys(); err != nil {
+		t.Fatalf("initKeys failed: %v", err)
+	}
+	if !ready.Load() {
+		t.Error("Expected ready after initKeys")
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Lifetime of opaque refresh tokens
+var refreshTTL = 7 * 24 * time.Hour
+
+var errInvalidRefreshToken = errors.New("invalid refresh token")
+
+type refreshEntry struct {
+	sub       string
+	family    string
+	expiresAt time.Time
+	used      bool
+}
+
+// Server-side refresh tokens. Each use rotates the token; presenting an
+// already-used token is treated as replay and revokes its whole family.
+type refreshStore struct {
+	mu      sync.Mutex
+	entries map[string]*refreshEntry
+}
+
+var refreshTokens = &refreshStore{entries: map[string]*refreshEntry{}}
+
+// Issues a new refresh token for sub; an empty family starts a new one
+func (rs *refreshStore) issue(sub, family string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	if family == "" {
+		family = token
+	}
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.pruneLocked(nowFunc())
+	rs.entries[token] = &refreshEntry{sub: sub, family: family, expiresAt: nowFunc().Add(refreshTTL)}
+	return token, nil
+}
+
+// Consumes token and returns a replacement along with the subject it belongs to
+func (rs *refreshStore) rotate(token string) (sub, next string, err error) {
+	rs.mu.Lock()
+	entry, ok := rs.entries[token]
+	switch {
+	case !ok || !nowFunc().Before(entry.expiresAt):
+		rs.mu.Unlock()
+		return "", "", errInvalidRefreshToken
+	case entry.used:
+		rs.revokeFamilyLocked(entry.family)
+		rs.mu.Unlock()
+		return "", "", errInvalidRefreshToken
+	}
+	entry.used = true
+	rs.mu.Unlock()
+
+	next, err = rs.issue(entry.sub, entry.family)
+	return entry.sub, next, err
+}
+
+func (rs *refreshStore) revokeFamilyLocked(family string) {
+	for token, e := range rs.entries {
+		if e.family == family {
+			delete(rs.entries, token)
+		}
+	}
+}
+
+func (rs *refreshStore) pruneLocked(now time.Time) {
+	for token, e := range rs.entries {
+		if !now.Before(e.expiresAt) {
+			delete(rs.entries, token)
+		}
+	}
+}
+
+// Exchanges a refresh token for a new access token and a rotated refresh token
+func refreshHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		respondError(w, 405, errors.New("use POST"))
+		return
+	}
+	var body struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := decodeJSON(w, r, &body); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	if body.RefreshToken == "" {
+		respondError(w, 400, errors.New("refresh_token is required"))
+		return
+	}
+	now := nowFunc()
+	kp, ok := signingKeyAt(now)
+	if !ok {
+		writeNoSigningKey(w, r)
+		return
+	}
+	sub, next, err := refreshTokens.rotate(body.RefreshToken)
+	if err != nil {
+		respondError(w, 401, errors.New("Invalid refresh token"))
+		return
+	}
+	token, err := issueToken(withRequestTime(withSourceIP(r), now), kp, sub, audience, tokenExpiry(now, tokenTTL, kp))
+	if err != nil {
+		writeSignError(w, r, err)
+		return
+	}
+	if err := respondJSON(w, 200, map[string]string{"token": token, "refresh_token": next}); err != nil {
+		logResponseError(w, r, err)
+	}
+}
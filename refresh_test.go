@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Exchange a refresh token and return the response code and body
+func refresh(t *testing.T, token string) (int, map[string]string) {
+	t.Helper()
+	body, _ := json.Marshal(map[string]string{"refresh_token": token})
+	w := httptest.NewRecorder()
+	refreshHandler(w, httptest.NewRequest("POST", "/refresh", bytes.NewReader(body)))
+	var env struct {
+		Data map[string]string `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &env)
+	return w.Code, env.Data
+}
+
+// Log in and return the /auth response
+func login(t *testing.T) map[string]string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	authHandler(w, loginRequest("/auth", "user123", "password123"))
+	resp := authResponse(t, w.Body.Bytes())
+	return resp
+}
+
+// Test /auth issues an opaque refresh token alongside the access token
+func TestAuthHandler_IssuesRefreshToken(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	resp := login(t)
+	if resp["token"] == "" || len(resp["refresh_token"]) != 43 {
+		t.Errorf("Expected access and 32-byte refresh token, got %v", resp)
+	}
+}
+
+// Test a refresh returns a new access token and rotates the refresh token
+func TestRefreshHandler_Rotates(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	first := login(t)["refresh_token"]
+
+	code, resp := refresh(t, first)
+	if code != 200 || resp["token"] == "" || resp["refresh_token"] == "" || resp["refresh_token"] == first {
+		t.Fatalf("Expected rotated tokens, got %d %v", code, resp)
+	}
+	if sub, _ := unverifiedClaims(t, resp["token"]).GetSubject(); sub != "user123" {
+		t.Errorf("Expected sub user123, got %q", sub)
+	}
+	if code, _ := refresh(t, resp["refresh_token"]); code != 200 {
+		t.Errorf("Expected rotated token to be usable, got %d", code)
+	}
+}
+
+// Test reusing a consumed refresh token is rejected and revokes its successor
+func TestRefreshHandler_ReuseRejected(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	first := login(t)["refresh_token"]
+	_, resp := refresh(t, first)
+
+	if code, _ := refresh(t, first); code != 401 {
+		t.Errorf("Expected 401 on reuse, got %d", code)
+	}
+	if code, _ := refresh(t, resp["refresh_token"]); code != 401 {
+		t.Errorf("Expected replay to revoke the token family, got %d", code)
+	}
+	if code, _ := refresh(t, "unknown"); code != 401 {
+		t.Errorf("Expected 401 for unknown token, got %d", code)
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+type requestTimeKey struct{}
+
+// Returns ctx carrying now as the request's single timestamp, so choosing a
+// signing key and stamping the token agree even if the clock crosses the
+// key's expiry in between
+func withRequestTime(ctx context.Context, now time.Time) context.Context {
+	return context.WithValue(ctx, requestTimeKey{}, now)
+}
+
+// Timestamp captured by withRequestTime, or the current time when none was
+func requestTime(ctx context.Context) time.Time {
+	if now, ok := ctx.Value(requestTimeKey{}).(time.Time); ok {
+		return now
+	}
+	return nowFunc()
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Test a clock reaching the key's expiry mid-request still yields a token stamped before it
+func TestAuthHandler_ClockCrossesKeyExpiry(t *testing.T) {
+	expiry := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	validKey, _ = generateKeyPair(expiry, 2048)
+	// The first reading is just inside the key's lifetime; every later one is at its expiry
+	calls := 0
+	nowFunc = func() time.Time {
+		if calls++; calls == 1 {
+			return expiry.Add(-time.Second)
+		}
+		return expiry
+	}
+	t.Cleanup(func() { nowFunc = time.Now })
+
+	w := httptest.NewRecorder()
+	authHandler(w, loginRequest("/auth", "user123", "password123"))
+	if w.Code != 200 {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+	}
+	claims := unverifiedClaims(t, authResponse(t, w.Body.Bytes())["token"])
+	iat, exp := int64(claims["iat"].(float64)), int64(claims["exp"].(float64))
+	if iat != expiry.Unix()-1 || exp != expiry.Unix() {
+		t.Errorf("Expected iat %d and exp %d, got %d and %d", expiry.Unix()-1, expiry.Unix(), iat, exp)
+	}
+}
+
+// Test the store hands out the signing key up to, but not at, its expiry
+func TestInMemoryStore_SigningKeyAtBoundary(t *testing.T) {
+	expiry := time.Now().Add(time.Hour)
+	validKey, _ = generateKeyPair(expiry, 2048)
+	if kp, err := keyStore.SigningKey(expiry.Add(-time.Nanosecond)); err != nil || kp != validKey {
+		t.Errorf("Expected the active key just before expiry, got %v, %v", kp, err)
+	}
+	if _, err := keyStore.SigningKey(expiry); !errors.Is(err, errNoActiveKey) {
+		t.Errorf("Expected errNoActiveKey at expiry, got %v", err)
+	}
+}
+
+// Test requestTime falls back to the clock without a captured timestamp
+func TestRequestTime(t *testing.T) {
+	at := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	if got := requestTime(withRequestTime(context.Background(), at)); !got.Equal(at) {
+		t.Errorf("Expected the captured time, got %v", got)
+	}
+	freezeClock(t, at.Add(time.Minute))
+	if got := requestTime(context.Background()); !got.Equal(at.Add(time.Minute)) {
+		t.Errorf("Expected the clock, got %v", got)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Revoked token IDs, each kept only until the token would have expired anyway
+type revocationList struct {
+	mu      sync.Mutex
+	entries map[string]time.Time
+}
+
+var revoked = &revocationList{entries: map[string]time.Time{}}
+
+func (rl *revocationList) revoke(jti string, exp time.Time) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.pruneLocked(nowFunc())
+	rl.entries[jti] = exp
+}
+
+func (rl *revocationList) isRevoked(jti string) bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	_, ok := rl.entries[jti]
+	return ok
+}
+
+// Drops entries whose tokens have expired, bounding memory
+func (rl *revocationList) pruneLocked(now time.Time) {
+	for jti, exp := range rl.entries {
+		if !now.Before(exp) {
+			delete(rl.entries, jti)
+		}
+	}
+}
+
+// Revokes a token by form value `token`, verified against the key ring before
+// its jti and exp are recorded, or by bare `jti`, which requires the admin
+// token since anyone who has seen a jti could otherwise revoke it
+func revokeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		respondError(w, 405, errors.New("use POST"))
+		return
+	}
+	jti := r.PostFormValue("jti")
+	// Without a token we cannot know its exp, but none outlives maxTokenTTL
+	exp := nowFunc().Add(maxTokenTTL)
+	if raw := r.PostFormValue("token"); raw != "" {
+		claims := jwt.MapClaims{}
+		if _, err := parseWithKeyRing(raw, claims); err != nil {
+			respondError(w, 400, errors.New("Invalid token"))
+			return
+		}
+		jti, _ = claims["jti"].(string)
+		if e, _ := claims.GetExpirationTime(); e != nil {
+			exp = e.Time
+		}
+	} else if jti != "" && !isAdmin(r) {
+		rejectNonAdmin(w)
+		return
+	}
+	if jti == "" {
+		respondError(w, 400, errors.New("Missing jti or token"))
+		return
+	}
+	revoked.revoke(jti, exp)
+	if err := respondJSON(w, 200, map[string]any{"jti": jti, "revoked": true}); err != nil {
+		logResponseError(w, r, err)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// POST form values to the revocation endpoint
+func revokeRequest(values url.Values) *httptest.ResponseRecorder {
+	return revokeRequestAs("", values)
+}
+
+// POST form values to the revocation endpoint with an optional admin bearer token
+func revokeRequestAs(bearer string, values url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/revoke", strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	w := httptest.NewRecorder()
+	revokeHandler(w, req)
+	return w
+}
+
+// Test an admin revoking a token's jti flips introspection to inactive
+func TestRevoke_ByJTI(t *testing.T) {
+	adminToken = "s3cret"
+	defer func() { adminToken = "" }()
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	keyRing = []*KeyPair{validKey}
+	token := mintToken(t, "/auth")
+	if !introspect(t, token).Active {
+		t.Fatal("Expected fresh token to be active")
+	}
+
+	jti, _ := unverifiedClaims(t, token)["jti"].(string)
+	if jti == "" {
+		t.Fatal("Expected jti claim")
+	}
+	if w := revokeRequestAs("s3cret", url.Values{"jti": {jti}}); w.Code != 200 {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	if introspect(t, token).Active {
+		t.Error("Expected revoked token to be inactive")
+	}
+}
+
+// Test a bare jti without the admin token is rejected and revokes nothing
+func TestRevoke_ByJTIRequiresAdmin(t *testing.T) {
+	adminToken = "s3cret"
+	defer func() { adminToken = "" }()
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	keyRing = []*KeyPair{validKey}
+	token := mintToken(t, "/auth")
+	jti, _ := unverifiedClaims(t, token)["jti"].(string)
+
+	for _, bearer := range []string{"", "wrong"} {
+		w := revokeRequestAs(bearer, url.Values{"jti": {jti}})
+		assertEnvelopeError(t, w, 401)
+		if w.Header().Get("WWW-Authenticate") == "" {
+			t.Error("Expected a WWW-Authenticate challenge")
+		}
+	}
+	if !introspect(t, token).Active {
+		t.Error("Expected the token to stay active")
+	}
+}
+
+// Test revoking by the token itself
+func TestRevoke_ByToken(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	keyRing = []*KeyPair{validKey}
+	token := mintToken(t, "/auth")
+	if w := revokeRequest(url.Values{"token": {token}}); w.Code != 200 {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	if introspect(t, token).Active {
+		t.Error("Expected revoked token to be inactive")
+	}
+}
+
+// Test revocation requires a jti or token
+func TestRevoke_Missing(t *testing.T) {
+	if w := revokeRequest(url.Values{}); w.Code != 400 {
+		t.Errorf("Expected 400, got %d", w.Code)
+	}
+}
+
+// Test entries are dropped once their token has expired
+func TestRevocationList_Prune(t *testing.T) {
+	rl := &revocationList{entries: map[string]time.Time{}}
+	rl.revoke("old", time.Now().Add(-time.Minute))
+	rl.revoke("new", time.Now().Add(time.Hour))
+	if rl.isRevoked("old") || !rl.isRevoked("new") {
+		t.Errorf("Unexpected entries after prune: %v", rl.entries)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Maximum number of key generations allowed to run at once
+var maxKeygen = 1
+
+// Semaphore bounding concurrent key generation; sized from maxKeygen at startup
+var keygenSlots = make(chan struct{}, 1)
+
+var errRotationInProgress = errors.New("rotation in progress")
+
+// Runs generate holding a keygenSlots slot. With wait false it returns
+// errRotationInProgress instead of waiting when every slot is busy.
+func withKeygenSlot(wait bool, generate func() (*KeyPair, error)) (*KeyPair, error) {
+	if wait {
+		keygenSlots <- struct{}{}
+	} else {
+		select {
+		case keygenSlots <- struct{}{}:
+		default:
+			return nil, errRotationInProgress
+		}
+	}
+	defer func() { <-keygenSlots }()
+	return generate()
+}
+
+// Serializes rotations, so each one publishes its key and webhook event whole
+var rotateMu sync.Mutex
+
+// Takes a new signing key (and encryption key, if enabled) from the pool or
+// generates it, and publishes it alongside the still-valid old ones. Only
+// generation needs a keygenSlots slot, so a pooled key is never held up by
+// a refill. When wait is false and another rotation is running, or a key
+// must be generated while every slot is busy, it returns errRotationInProgress.
+func rotateKeys(wait bool) (*KeyPair, error) {
+	if wait {
+		rotateMu.Lock()
+	} else if !rotateMu.TryLock() {
+		return nil, errRotationInProgress
+	}
+	defer rotateMu.Unlock()
+
+	kp, err := nextKey(nowFunc().Add(keyLifetime), wait)
+	if err == nil {
+		err = validateKeyPair(kp)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := refreshEncKey(kp.ExpiresAt); err != nil {
+		return nil, err
+	}
+	before := publishedKeys(nowFunc())
+	if err := keyStore.Add(kp); err != nil {
+		return nil, err
+	}
+	now := nowFunc()
+	if _, err := keyStore.Prune(now); err != nil {
+		return nil, err
+	}
+	notifyRotation(newRotationEvent(now, before, publishedKeys(now)))
+	rotateTenants(now)
+	return kp, nil
+}
+
+// Rotates the signing key every interval until ctx is cancelled
+func rotationLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if kp, err := rotateKeys(true); err != nil {
+				logger.Error("key rotation failed", "error", err)
+			} else {
+				logger.Info("rotated signing key", "kid", kp.Kid)
+			}
+		}
+	}
+}
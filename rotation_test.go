@@ -0,0 +1,142 @@
+package main
+
+import (
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Test rotation swaps the signing key and keeps the old one published
+func TestRotateKeys(t *testing.T) {
+	old, _ := generateKeyPair(time.Now().Add(time.Hour), 2048)
+	validKey = old
+	setKeyRing([]*KeyPair{old})
+
+	kp, err := rotateKeys(true)
+	if err != nil {
+		t.Fatalf("rotateKeys failed: %v", err)
+	}
+	if validKey != kp || kp == old {
+		t.Error("Expected the new key to become the signing key")
+	}
+	if len(keyRing) != 2 || keyRing[0] != old || keyRing[1] != kp {
+		t.Errorf("Expected old and new keys published, got %d keys", len(keyRing))
+	}
+}
+
+// Test concurrent rotate calls generate only one key
+func TestRotateHandler_Concurrent(t *testing.T) {
+	adminToken = "s3cret"
+	defer func() { adminToken = "" }()
+	var generated atomic.Int32
+	original := generateKeyPairFunc
+	key, _ := generateKeyPair(time.Now().Add(time.Hour), 2048)
+	generateKeyPairFunc = func(expiresAt time.Time, bits int) (*KeyPair, error) {
+		generated.Add(1)
+		time.Sleep(100 * time.Millisecond)
+		return &KeyPair{Kid: "slow", PrivateKey: key.PrivateKey, PublicKey: key.PublicKey, ExpiresAt: expiresAt}, nil
+	}
+	defer func() { generateKeyPairFunc = original }()
+
+	var wg sync.WaitGroup
+	codes := make(chan int, 5)
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- adminRequest("POST", "/admin/rotate", "s3cret").Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	ok, busy := 0, 0
+	for code := range codes {
+		switch code {
+		case 200:
+			ok++
+		case 503:
+			busy++
+		}
+	}
+	if generated.Load() != 1 || ok != 1 || busy != 4 {
+		t.Errorf("Expected 1 generation (1 ok, 4 busy), got %d generations, %d ok, %d busy", generated.Load(), ok, busy)
+	}
+}
+
+// Test JWKS reads racing a rotation stay consistent (run with -race)
+func TestRotateKeys_ConcurrentReads(t *testing.T) {
+	initial, _ := generateKeyPair(time.Now().Add(time.Hour), 2048)
+	validKey = initial
+	setKeyRing([]*KeyPair{initial})
+	original := generateKeyPairFunc
+	generateKeyPairFunc = func(expiresAt time.Time, bits int) (*KeyPair, error) {
+		return finalizeKeyPair(&KeyPair{Alg: "RS256", PrivateKey: initial.PrivateKey, PublicKey: initial.PublicKey, ExpiresAt: expiresAt})
+	}
+	defer func() { generateKeyPairFunc = original }()
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				w := httptest.NewRecorder()
+				jwksHandler(w, httptest.NewRequest("GET", "/.well-known/jwks.json", nil))
+				if w.Code != 200 {
+					t.Errorf("Expected 200 during rotation, got %d", w.Code)
+					return
+				}
+			}
+		}()
+	}
+	for range 5 {
+		if _, err := rotateKeys(true); err != nil {
+			t.Errorf("rotateKeys failed: %v", err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
+
+// Test rotating past -max-keys keeps only the newest keys
+func TestRotateKeys_MaxRetainedKeys(t *testing.T) {
+	maxRetainedKeys = 5
+	defer func() { maxRetainedKeys = 0 }()
+	validKey, keyRing = nil, nil
+	base, _ := generateKeyPair(time.Now().Add(time.Hour), 2048)
+	original := generateKeyPairFunc
+	generateKeyPairFunc = func(expiresAt time.Time, bits int) (*KeyPair, error) {
+		return finalizeKeyPair(&KeyPair{Alg: "RS256", PrivateKey: base.PrivateKey, PublicKey: base.PublicKey, ExpiresAt: expiresAt})
+	}
+	defer func() { generateKeyPairFunc = original }()
+
+	// Each rotation a minute later, so every key expires after the previous one
+	start := time.Now()
+	var rotated []*KeyPair
+	for i := range 10 {
+		freezeClock(t, start.Add(time.Duration(i)*time.Minute))
+		kp, err := rotateKeys(true)
+		if err != nil {
+			t.Fatalf("rotateKeys failed: %v", err)
+		}
+		rotated = append(rotated, kp)
+	}
+	all, _ := keyStore.All()
+	if len(all) != 5 {
+		t.Fatalf("Expected 5 keys, got %d", len(all))
+	}
+	for i, kp := range rotated[5:] {
+		if all[i] != kp {
+			t.Errorf("Expected key %d to be rotation %d (%s), got %s", i, i+5, kp.Kid, all[i].Kid)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"crypto/rsa"
+	"io"
+	"math/big"
+)
+
+// Derives an RSA key whose primes come only from random, so the same stream
+// always yields the same key. Only math/big is involved: rsa.GenerateKey
+// may ignore readers other than crypto/rand.Reader.
+func deriveRSAKey(random io.Reader, bits int) (*rsa.PrivateKey, error) {
+	e := big.NewInt(65537)
+	one := big.NewInt(1)
+	for {
+		p, err := derivePrime(random, bits-bits/2)
+		if err != nil {
+			return nil, err
+		}
+		q, err := derivePrime(random, bits/2)
+		if err != nil {
+			return nil, err
+		}
+		if p.Cmp(q) == 0 {
+			continue
+		}
+		phi := new(big.Int).Mul(new(big.Int).Sub(p, one), new(big.Int).Sub(q, one))
+		d := new(big.Int).ModInverse(e, phi)
+		if d == nil {
+			continue
+		}
+		key := &rsa.PrivateKey{
+			PublicKey: rsa.PublicKey{N: new(big.Int).Mul(p, q), E: int(e.Int64())},
+			D:         d,
+			Primes:    []*big.Int{p, q},
+		}
+		key.Precompute()
+		if err := key.Validate(); err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+}
+
+// First probable prime of exactly bits bits at or above a candidate read
+// from random. The top two bits are set so the product of two such primes
+// has the full modulus size.
+func derivePrime(random io.Reader, bits int) (*big.Int, error) {
+	buf := make([]byte, (bits+7)/8)
+	for {
+		if _, err := io.ReadFull(random, buf); err != nil {
+			return nil, err
+		}
+		p := new(big.Int).SetBytes(buf)
+		p.Rsh(p, uint(len(buf)*8-bits))
+		p.SetBit(p, bits-1, 1)
+		p.SetBit(p, bits-2, 1)
+		p.SetBit(p, 0, 1)
+		for ; p.BitLen() == bits; p.Add(p, big.NewInt(2)) {
+			if p.ProbablyPrime(20) {
+				return p, nil
+			}
+		}
+	}
+}
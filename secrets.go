@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Resolves a secret flag value: "env:NAME" reads the environment variable
+// NAME and "@path" reads the file at path (one trailing newline dropped), so
+// secrets need not appear in the process list. Anything else is the literal
+// secret.
+func resolveSecret(val string) (string, error) {
+	switch {
+	case strings.HasPrefix(val, "env:"):
+		name := strings.TrimPrefix(val, "env:")
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return v, nil
+	case strings.HasPrefix(val, "@"):
+		data, err := os.ReadFile(strings.TrimPrefix(val, "@"))
+		if err != nil {
+			return "", fmt.Errorf("read secret: %w", err)
+		}
+		s := strings.TrimSuffix(string(data), "\n")
+		return strings.TrimSuffix(s, "\r"), nil
+	}
+	return val, nil
+}
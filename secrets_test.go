@@ -0,0 +1,65 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Test literal, env: and @file secrets resolve, and a missing file is an error
+func TestResolveSecret(t *testing.T) {
+	t.Setenv("TEST_ADMIN_TOKEN", "from-env")
+	path := filepath.Join(t.TempDir(), "admin")
+	os.WriteFile(path, []byte("from-file\n"), 0o600)
+
+	for val, want := range map[string]string{
+		"literal":              "literal",
+		"env:TEST_ADMIN_TOKEN": "from-env",
+		"@" + path:             "from-file",
+	} {
+		if got, err := resolveSecret(val); err != nil || got != want {
+			t.Errorf("resolveSecret(%q) = %q, %v; want %q", val, got, err, want)
+		}
+	}
+	if _, err := resolveSecret("@" + filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+	if _, err := resolveSecret("env:TEST_UNSET_SECRET"); err == nil {
+		t.Error("Expected an error for an unset variable")
+	}
+}
+
+// Test -admin-token accepts the indirect forms
+func TestParseFlags_AdminTokenFromEnv(t *testing.T) {
+	t.Setenv("TEST_ADMIN_TOKEN", "s3cret")
+	if err := parseTestFlags(t, "-admin-token", "env:TEST_ADMIN_TOKEN"); err != nil {
+		t.Fatalf("parseFlags failed: %v", err)
+	}
+	if adminToken != "s3cret" {
+		t.Errorf("Expected the token from the environment, got %q", adminToken)
+	}
+	if err := parseTestFlags(t, "-admin-token", "@/nonexistent/admin"); err == nil {
+		t.Error("Expected an error for an unreadable secret file")
+	}
+}
+
+// Test -key-passphrase read from a file decrypts an encrypted -key-file
+func TestParseFlags_KeyPassphraseDecryptsKeyFile(t *testing.T) {
+	priv, _ := rsa.GenerateKey(rand.Reader, 2048)
+	data, _ := encryptPrivateKey(priv, []byte("correct horse"))
+	passFile := filepath.Join(t.TempDir(), "passphrase")
+	os.WriteFile(passFile, []byte("correct horse\n"), 0600)
+
+	if err := parseTestFlags(t, "-key-passphrase", "@"+passFile); err != nil {
+		t.Fatalf("parseFlags failed: %v", err)
+	}
+	useKeyFile(t, encryptedKeyBlock, data)
+	if err := initKeys(); err != nil {
+		t.Fatalf("initKeys failed: %v", err)
+	}
+	if !validKey.PrivateKey.Equal(priv) {
+		t.Error("Expected validKey to be the decrypted key")
+	}
+}
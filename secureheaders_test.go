@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+// Test all hardening headers are sent over TLS
+func TestSecureHeaders_TLS(t *testing.T) {
+	secureHeaders = true
+	defer func() { secureHeaders = false }()
+	w := httptest.NewRecorder()
+	newServer(":0").Handler.ServeHTTP(w, httptest.NewRequest("GET", "https://localhost/healthz", nil))
+	for name, want := range map[string]string{
+		"Strict-Transport-Security": hstsValue,
+		"X-Content-Type-Options":    "nosniff",
+		"Referrer-Policy":           "no-referrer",
+	} {
+		if got := w.Header().Get(name); got != want {
+			t.Errorf("Expected %s %q, got %q", name, want, got)
+		}
+	}
+}
+
+// Test HSTS is omitted over plaintext HTTP
+func TestSecureHeaders_PlaintextNoHSTS(t *testing.T) {
+	secureHeaders = true
+	defer func() { secureHeaders = false }()
+	w := httptest.NewRecorder()
+	newServer(":0").Handler.ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+	if got := w.Header().Get("Strict-Transport-Security"); got != "" {
+		t.Errorf("Expected no HSTS over HTTP, got %q", got)
+	}
+	if w.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Error("Expected nosniff over HTTP")
+	}
+}
+
+// Test the headers default to on only when TLS is configured
+func TestParseFlags_SecureHeadersDefault(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want bool
+	}{
+		{nil, false},
+		{[]string{"-tls-cert", "cert.pem", "-tls-key", "key.pem"}, true},
+		{[]string{"-tls-cert", "cert.pem", "-tls-key", "key.pem", "-secure-headers=false"}, false},
+		{[]string{"-secure-headers"}, true},
+	} {
+		if err := parseTestFlags(t, tc.args...); err != nil {
+			t.Fatal(err)
+		}
+		if secureHeaders != tc.want {
+			t.Errorf("%v: expected secure headers %v, got %v", tc.args, tc.want, secureHeaders)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Skips the startup sign-and-verify check
+var skipSelfTest bool
+
+// Signs a throwaway token with the signing key and verifies it against the
+// public key, catching signer/key mismatches before serving traffic
+func selfTest() error {
+	kp, err := keyStore.Active()
+	if err != nil {
+		return fmt.Errorf("self-test: %w", err)
+	}
+	method := kp.signingMethod()
+	token := jwt.NewWithClaims(method, jwt.MapClaims{"sub": "self-test", "exp": nowFunc().Add(time.Minute).Unix()})
+	token.Header["kid"] = kp.Kid
+
+	ctx, cancel := context.WithTimeout(context.Background(), signTimeout)
+	defer cancel()
+	signed, err := signFunc(ctx, kp.signingKey(), method, token)
+	if err != nil {
+		return fmt.Errorf("self-test: sign: %w", err)
+	}
+	_, err = jwt.Parse(signed, func(*jwt.Token) (any, error) {
+		return kp.verificationKey(), nil
+	}, jwt.WithValidMethods([]string{method.Alg()}))
+	if err != nil {
+		return fmt.Errorf("self-test: verify with key %s: %w", kp.Kid, err)
+	}
+	return nil
+}
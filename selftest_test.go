@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Test the self-test passes with a matching signer
+func TestSelfTest(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	if err := selfTest(); err != nil {
+		t.Errorf("Expected self-test to pass: %v", err)
+	}
+	validKey, _ = generateECKeyPair(time.Now().Add(time.Hour), 0)
+	if err := selfTest(); err != nil {
+		t.Errorf("Expected ES256 self-test to pass: %v", err)
+	}
+}
+
+// Test a signer using the wrong key fails the self-test
+func TestSelfTest_SignerMismatch(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	other, _ := generateKeyPair(time.Now().Add(time.Hour), 2048)
+	original := signFunc
+	signFunc = func(_ context.Context, _ crypto.PrivateKey, _ jwt.SigningMethod, token *jwt.Token) (string, error) {
+		return token.SignedString(other.PrivateKey)
+	}
+	defer func() { signFunc = original }()
+
+	if err := selfTest(); err == nil {
+		t.Error("Expected self-test to fail for a mismatched signer")
+	}
+}
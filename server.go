@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Also serve the JWKS at /jwks.json and redirect /jwks to the well-known path
+var jwksAlias = true
+
+// Routes served by the JWKS server
+func newRouter() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/jwks.json", withLogging(withCORS(corsOrigins, "GET", withAllow("GET", jwksHandler))))
+	if jwksAlias {
+		mux.HandleFunc("/jwks.json", withLogging(withCORS(corsOrigins, "GET", withAllow("GET", jwksHandler))))
+		mux.Handle("/jwks", http.RedirectHandler("/.well-known/jwks.json", http.StatusMovedPermanently))
+	}
+	mux.HandleFunc("/jwks/{kid}", withLogging(withCORS(corsOrigins, "GET", withAllow("GET", jwkHandler))))
+	mux.HandleFunc("/auth", withLogging(withBodyLimit(withCORS(authCORSOrigins, "GET, POST", withAllow("GET, POST", authHandler)))))
+	mux.HandleFunc("/tenants/{tenant}/.well-known/jwks.json", withLogging(withCORS(corsOrigins, "GET", withAllow("GET", tenantJWKSHandler))))
+	mux.HandleFunc("/tenants/{tenant}/auth", withLogging(withBodyLimit(withCORS(authCORSOrigins, "GET, POST", withAllow("GET, POST", tenantAuthHandler)))))
+	mux.HandleFunc("/auth/batch", withLogging(withBodyLimit(withAllow("POST", requireAdmin(batchAuthHandler)))))
+	mux.HandleFunc("/refresh", withLogging(withBodyLimit(withAllow("POST", refreshHandler))))
+	mux.HandleFunc("/introspect", withLogging(withBodyLimit(withAllow("POST", introspectHandler))))
+	mux.HandleFunc("/revoke", withLogging(withBodyLimit(withAllow("POST", revokeHandler))))
+	mux.HandleFunc("/admin/rotate", withLogging(withBodyLimit(withAllow("POST", requireAdmin(rotateHandler)))))
+	mux.HandleFunc("/admin/audit", withLogging(withAllow("GET", requireAdmin(auditHandler))))
+	mux.HandleFunc("/me", withLogging(withAllow("GET", requireJWT(meHandler))))
+	mux.HandleFunc("/healthz", withLogging(withAllow("GET", healthHandler)))
+	mux.HandleFunc("/debug/decode", withLogging(withAllow("GET", debugDecodeHandler)))
+	mux.HandleFunc("/version", withLogging(withAllow("GET", versionHandler)))
+	mux.HandleFunc("/.well-known/openid-configuration", withLogging(withCORS(corsOrigins, "GET", withAllow("GET", discoveryHandler))))
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/", withLogging(notFoundHandler))
+	return mux
+}
+
+// Answers paths no route matches with a JSON problem instead of Go's plain-text 404
+func notFoundHandler(w http.ResponseWriter, r *http.Request) {
+	logger.Info("unknown path", "method", r.Method, "path", r.URL.Path)
+	respondError(w, 404, fmt.Errorf("no endpoint at %s", r.URL.Path))
+}
+
+// Answers OPTIONS with 204 and an Allow header, and adds Allow to 405 responses
+func withAllow(methods string, next http.HandlerFunc) http.HandlerFunc {
+	allow := methods + ", OPTIONS"
+	allowed := strings.Split(methods, ", ")
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case slices.Contains(allowed, r.Method):
+		case r.Method == "OPTIONS":
+			w.Header().Set("Allow", allow)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		default:
+			w.Header().Set("Allow", allow)
+		}
+		next(w, r)
+	}
+}
+
+// HTTP server for the router with timeouts against slow or idle clients
+func newServer(addr string) *http.Server {
+	var handler http.Handler = newRouter()
+	if secureHeaders {
+		handler = withSecureHeaders(handler)
+	}
+	handler = withGzip(handler)
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+	}
+}
+
+// Returned by listen when another process already holds the address
+var errAddrInUse = errors.New("address already in use")
+
+// Binds addr and reports the concrete address, which matters when the port is 0
+func listen(addr string, out io.Writer) (net.Listener, error) {
+	ln, err := net.Listen("tcp", addr)
+	if errors.Is(err, syscall.EADDRINUSE) {
+		return nil, fmt.Errorf("cannot listen on %s: %w; stop the other process or choose another address with -addr", addr, errAddrInUse)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot listen on %s: %w", addr, err)
+	}
+	fmt.Fprintf(out, "🔐 JWKS Server starting on %s\n", ln.Addr())
+	return ln, nil
+}
+
+// Serves on ln (over TLS when srv.TLSConfig is set) until ctx is cancelled, then drains in-flight requests for up to drain
+func serve(ctx context.Context, srv *http.Server, ln net.Listener, drain time.Duration) error {
+	errCh := make(chan error, 1)
+	go func() {
+		if srv.TLSConfig != nil {
+			errCh <- srv.ServeTLS(ln, "", "")
+		} else {
+			errCh <- srv.Serve(ln)
+		}
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), drain)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
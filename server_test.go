@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test shutdown drains an in-flight slow request
+func TestServe_GracefulShutdown(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{})
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		io.WriteString(w, "done")
+	})}
+	ctx, cancel := context.WithCancel(context.Background())
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- serve(ctx, srv, ln, 5*time.Second) }()
+
+	respCh := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			respCh <- "error: " + err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		respCh <- string(body)
+	}()
+
+	<-started
+	cancel()
+	if err := <-serveErr; err != nil {
+		t.Errorf("Expected clean shutdown, got %v", err)
+	}
+	if body := <-respCh; body != "done" {
+		t.Errorf("Expected slow request to complete, got %q", body)
+	}
+}
+
+// Test listening on :0 reports the concrete port
+func TestListen_ReportsPort(t *testing.T) {
+	var out bytes.Buffer
+	ln, err := listen("127.0.0.1:0", &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	if port == "0" || !strings.Contains(out.String(), "127.0.0.1:"+port) {
+		t.Errorf("Expected startup line with port %s, got %q", port, out.String())
+	}
+}
+
+// Test a taken port yields the friendly address-in-use error
+func TestListen_AddrInUse(t *testing.T) {
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taken.Close()
+	addr := taken.Addr().String()
+
+	var out bytes.Buffer
+	ln, err := listen(addr, &out)
+	if err == nil {
+		ln.Close()
+		t.Fatal("Expected listen on a taken port to fail")
+	}
+	if !errors.Is(err, errAddrInUse) || !strings.Contains(err.Error(), addr) || !strings.Contains(err.Error(), "-addr") {
+		t.Errorf("Expected friendly address-in-use error naming %s, got %v", addr, err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected no startup line, got %q", out.String())
+	}
+}
+
+// Test other listen failures are not reported as address-in-use
+func TestListen_OtherError(t *testing.T) {
+	_, err := listen("127.0.0.1:notaport", io.Discard)
+	if err == nil || errors.Is(err, errAddrInUse) {
+		t.Errorf("Expected a distinct listen error, got %v", err)
+	}
+}
+
+// Test the server is built with the configured timeouts
+func TestNewServer_Timeouts(t *testing.T) {
+	srv := newServer(":0")
+	if srv.ReadHeaderTimeout != readHeaderTimeout || srv.ReadTimeout != readTimeout ||
+		srv.WriteTimeout != writeTimeout || srv.IdleTimeout != idleTimeout {
+		t.Errorf("Unexpected timeouts: %+v", srv)
+	}
+	if srv.ReadHeaderTimeout <= 0 || srv.ReadTimeout <= 0 || srv.WriteTimeout <= 0 || srv.IdleTimeout <= 0 {
+		t.Error("Expected every timeout to be set by default")
+	}
+}
+
+// Test an oversized /auth body is rejected through the router
+func TestRouter_OversizedAuthBody(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	body := `{"username":"` + strings.Repeat("a", 2*maxBodyBytes) + `"}`
+	w := httptest.NewRecorder()
+	newRouter().ServeHTTP(w, httptest.NewRequest("POST", "/auth", strings.NewReader(body)))
+	assertEnvelopeError(t, w, 413)
+}
+
+// Test /jwks.json serves the same JWKS as the well-known path
+func TestRouter_JWKSAlias(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	keyRing = []*KeyPair{validKey}
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		newRouter().ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+	canonical, alias := get("/.well-known/jwks.json"), get("/jwks.json")
+	if alias.Code != 200 || alias.Body.String() != canonical.Body.String() {
+		t.Errorf("Expected identical JWKS, got %d %q vs %q", alias.Code, alias.Body.String(), canonical.Body.String())
+	}
+
+	w := get("/jwks")
+	if w.Code != 301 || w.Header().Get("Location") != "/.well-known/jwks.json" {
+		t.Errorf("Expected 301 to the well-known path, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+}
+
+// Test the aliases can be disabled
+func TestRouter_JWKSAliasDisabled(t *testing.T) {
+	jwksAlias = false
+	defer func() { jwksAlias = true }()
+	w := httptest.NewRecorder()
+	newRouter().ServeHTTP(w, httptest.NewRequest("GET", "/jwks", nil))
+	if w.Code != 404 {
+		t.Errorf("Expected 404 with aliases disabled, got %d", w.Code)
+	}
+}
+
+// Test OPTIONS advertises each route's methods
+func TestRouter_Options(t *testing.T) {
+	for path, want := range map[string]string{
+		"/auth":                  "GET, POST, OPTIONS",
+		"/.well-known/jwks.json": "GET, OPTIONS",
+		"/admin/rotate":          "POST, OPTIONS",
+	} {
+		w := httptest.NewRecorder()
+		newRouter().ServeHTTP(w, httptest.NewRequest("OPTIONS", path, nil))
+		if w.Code != 204 || w.Header().Get("Allow") != want {
+			t.Errorf("%s: expected 204 with Allow %q, got %d %q", path, want, w.Code, w.Header().Get("Allow"))
+		}
+	}
+}
+
+// Test 405 responses carry an Allow header
+func TestRouter_MethodNotAllowedAllow(t *testing.T) {
+	w := httptest.NewRecorder()
+	newRouter().ServeHTTP(w, httptest.NewRequest("DELETE", "/.well-known/jwks.json", nil))
+	assertEnvelopeError(t, w, 405)
+	if w.Header().Get("Allow") != "GET, OPTIONS" {
+		t.Errorf("Expected Allow header on 405, got %q", w.Header().Get("Allow"))
+	}
+}
+
+// Test unknown paths get a logged JSON 404 rather than Go's plain-text one
+func TestRouter_NotFound(t *testing.T) {
+	var buf bytes.Buffer
+	original := logger
+	logger = slog.New(slog.NewJSONHandler(&buf, nil))
+	defer func() { logger = original }()
+
+	w := httptest.NewRecorder()
+	newRouter().ServeHTTP(w, httptest.NewRequest("GET", "/no/such/path", nil))
+	if p := assertEnvelopeError(t, w, 404); p.Title != "Not Found" || p.Detail != "no endpoint at /no/such/path" {
+		t.Errorf("Unexpected problem %+v", p)
+	}
+	if !strings.Contains(buf.String(), `"msg":"unknown path","method":"GET","path":"/no/such/path"`) {
+		t.Errorf("Expected the unknown path logged, got %s", buf.String())
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"sync"
+)
+
+// Tokens /auth may sign with one key before rotating to a new one (0 disables)
+var rotateAfterSigns int
+
+// Signatures reserved per kid, guarded by signCountMu
+var (
+	signCountMu sync.Mutex
+	signCounts  = map[string]int{}
+	// Serializes count-triggered rotations so concurrent requests rotate once
+	signRotateMu sync.Mutex
+)
+
+// Reserves one signature with kp, reporting false once kp has used up rotateAfterSigns
+func reserveSign(kp *KeyPair) bool {
+	signCountMu.Lock()
+	defer signCountMu.Unlock()
+	if signCounts[kp.Kid] >= rotateAfterSigns {
+		return false
+	}
+	signCounts[kp.Kid]++
+	return true
+}
+
+// Returns a signature reserved with kp whose token was never issued
+func releaseSign(kp *KeyPair) {
+	signCountMu.Lock()
+	defer signCountMu.Unlock()
+	if signCounts[kp.Kid] > 0 {
+		signCounts[kp.Kid]--
+	}
+}
+
+// Reserves a signature with kp, first rotating to a new key if kp is used up.
+// Returns the key to sign with.
+func signingKeyWithBudget(kp *KeyPair) (*KeyPair, error) {
+	for !reserveSign(kp) {
+		var err error
+		if kp, err = rotateExhausted(kp); err != nil {
+			return nil, err
+		}
+	}
+	return kp, nil
+}
+
+// Rotates away from exhausted unless another request already has
+func rotateExhausted(exhausted *KeyPair) (*KeyPair, error) {
+	signRotateMu.Lock()
+	defer signRotateMu.Unlock()
+	if active, err := keyStore.Active(); err == nil && active != exhausted {
+		return active, nil
+	}
+	kp, err := rotateKeys(true)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("rotated signing key after signature limit", "old_kid", exhausted.Kid, "kid", kp.Kid, "limit", rotateAfterSigns)
+	// Keep the exhausted key's count, since requests that read it before the
+	// rotation may still try to reserve with it; forget only keys no longer stored
+	all, _ := keyStore.All()
+	signCountMu.Lock()
+	defer signCountMu.Unlock()
+	maps.DeleteFunc(signCounts, func(kid string, _ int) bool {
+		return !slices.ContainsFunc(all, func(k *KeyPair) bool { return k.Kid == kid })
+	})
+	return kp, nil
+}
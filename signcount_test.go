@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// kid header of a token, without verifying it
+func tokenKid(t *testing.T, token string) string {
+	t.Helper()
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		t.Fatalf("Bad token: %v", err)
+	}
+	kid, _ := parsed.Header["kid"].(string)
+	return kid
+}
+
+// Use a fast generator and a fresh ring and counters with a signature limit
+func setupSignLimit(t *testing.T, limit int) {
+	t.Helper()
+	rotateAfterSigns = limit
+	base, _ := generateKeyPair(time.Now().Add(time.Hour), 2048)
+	validKey = base
+	setKeyRing([]*KeyPair{base})
+	signCounts = map[string]int{}
+	original := generateKeyPairFunc
+	generateKeyPairFunc = func(expiresAt time.Time, bits int) (*KeyPair, error) {
+		return finalizeKeyPair(&KeyPair{Alg: "RS256", PrivateKey: base.PrivateKey, PublicKey: base.PublicKey, ExpiresAt: expiresAt})
+	}
+	t.Cleanup(func() { rotateAfterSigns, generateKeyPairFunc = 0, original })
+}
+
+// Test the fourth token is signed by a new key when the limit is 3
+func TestAuthHandler_RotateAfterSigns(t *testing.T) {
+	setupSignLimit(t, 3)
+	first := validKey.Kid
+	for i := range 4 {
+		kid := tokenKid(t, mintToken(t, "/auth"))
+		if i < 3 && kid != first {
+			t.Errorf("Token %d: expected kid %s, got %s", i+1, first, kid)
+		}
+		if i == 3 && kid == first {
+			t.Error("Expected the fourth token to be signed by a rotated key")
+		}
+	}
+}
+
+// Test concurrent logins never sign more than the limit with one key
+func TestAuthHandler_RotateAfterSignsConcurrent(t *testing.T) {
+	setupSignLimit(t, 3)
+	recorders := make([]*httptest.ResponseRecorder, 10)
+	var wg sync.WaitGroup
+	for i := range recorders {
+		recorders[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			authHandler(recorders[i], loginRequest("/auth", "user123", "password123"))
+		}()
+	}
+	wg.Wait()
+	perKid := map[string]int{}
+	for _, w := range recorders {
+		perKid[tokenKid(t, authResponse(t, w.Body.Bytes())["token"])]++
+	}
+	if len(perKid) != 4 {
+		t.Errorf("Expected 10 tokens across 4 keys, got %v", perKid)
+	}
+	for kid, n := range perKid {
+		if n > 3 {
+			t.Errorf("Key %s signed %d tokens, over the limit", kid, n)
+		}
+	}
+}
+
+// Test requests rejected by validation or the claims hook use no signatures
+func TestAuthHandler_RotateAfterSignsIgnoresRejected(t *testing.T) {
+	setupSignLimit(t, 1)
+	first := validKey.Kid
+	for _, target := range []string{"/auth?audience=unlisted", "/auth?ttl=forever"} {
+		w := httptest.NewRecorder()
+		authHandler(w, loginRequest(target, "user123", "password123"))
+		assertEnvelopeError(t, w, 400)
+	}
+	originalValidate := validateClaims
+	validateClaims = func(jwt.MapClaims) error { return errors.New("not today") }
+	w := httptest.NewRecorder()
+	authHandler(w, loginRequest("/auth", "user123", "password123"))
+	validateClaims = originalValidate
+	assertEnvelopeError(t, w, 403)
+
+	if kid := tokenKid(t, mintToken(t, "/auth")); kid != first {
+		t.Errorf("Expected the first issued token to use the original key %s, got %s", first, kid)
+	}
+	if signCounts[first] != 1 {
+		t.Errorf("Expected one signature counted, got %d", signCounts[first])
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Request header carrying a subject already authenticated by an upstream
+// gateway, such as X-Auth-Subject. Empty (the default) ignores any such
+// header; only set it when clients cannot reach the server directly.
+var trustSubjectHeader string
+
+// Subject from the trusted header, if one is configured and present
+func trustedSubject(r *http.Request) (string, bool) {
+	if trustSubjectHeader == "" {
+		return "", false
+	}
+	sub := strings.TrimSpace(r.Header.Get(trustSubjectHeader))
+	return sub, sub != ""
+}
+
+// Default -sub-pattern: letters, digits and ._@- as in "user123", at most 256 of them
+const defaultSubPattern = `^[A-Za-z0-9._@-]{1,256}$`
+
+// Every subject must match this before a token is issued
+var subPattern = regexp.MustCompile(defaultSubPattern)
+
+var errInvalidSubject = errors.New("invalid subject")
+
+// Registers a regular expression flag, resetting *p to def
+func regexpVar(fs *flag.FlagSet, p **regexp.Regexp, name, def, usage string) {
+	*p = regexp.MustCompile(def)
+	fs.Func(name, usage, func(s string) error {
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return err
+		}
+		*p = re
+		return nil
+	})
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test a trusted header supplies the subject without credentials
+func TestAuthHandler_TrustedSubjectHeader(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	trustSubjectHeader = "X-Auth-Subject"
+	defer func() { trustSubjectHeader = "" }()
+
+	req := httptest.NewRequest("GET", "/auth", nil)
+	req.Header.Set("X-Auth-Subject", "alice")
+	w := httptest.NewRecorder()
+	authHandler(w, req)
+	if w.Code != 200 {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if sub := unverifiedClaims(t, authResponse(t, w.Body.Bytes())["token"])["sub"]; sub != "alice" {
+		t.Errorf("Expected sub alice from the header, got %v", sub)
+	}
+}
+
+// Test the header is ignored unless trusted
+func TestAuthHandler_UntrustedSubjectHeader(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+
+	req := httptest.NewRequest("GET", "/auth", nil)
+	req.Header.Set("X-Auth-Subject", "alice")
+	w := httptest.NewRecorder()
+	authHandler(w, req)
+	assertEnvelopeError(t, w, 401)
+
+	req = loginRequest("/auth", "user123", "password123")
+	req.Header.Set("X-Auth-Subject", "alice")
+	w = httptest.NewRecorder()
+	authHandler(w, req)
+	if sub := unverifiedClaims(t, authResponse(t, w.Body.Bytes())["token"])["sub"]; sub != "user123" {
+		t.Errorf("Expected sub from the credentials, got %v", sub)
+	}
+}
+
+// Log in with the subject supplied by a trusted header
+func headerLogin(sub string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/auth", nil)
+	req.Header.Set("X-Auth-Subject", sub)
+	w := httptest.NewRecorder()
+	authHandler(w, req)
+	return w
+}
+
+// Test subjects must match -sub-pattern before a token is issued
+func TestAuthHandler_SubjectPattern(t *testing.T) {
+	validKey, _ = generateKeyPair(time.Now().Add(time.Hour), 2048)
+	trustSubjectHeader = "X-Auth-Subject"
+	defer func() { trustSubjectHeader = "" }()
+
+	w := headerLogin("alice.smith@example.com")
+	if sub := unverifiedClaims(t, authResponse(t, w.Body.Bytes())["token"])["sub"]; sub != "alice.smith@example.com" {
+		t.Errorf("Expected the matching subject to be issued, got %v", sub)
+	}
+	for _, sub := range []string{"alice<script>", "bob admin", "carol\"}"} {
+		if p := assertEnvelopeError(t, headerLogin(sub), 400); !strings.Contains(p.Detail, "invalid subject") {
+			t.Errorf("%q: unexpected detail %q", sub, p.Detail)
+		}
+	}
+}
+
+// Test -sub-pattern replaces the default and rejects an invalid expression
+func TestParseFlags_SubPattern(t *testing.T) {
+	if err := parseTestFlags(t, "-sub-pattern", `^user[0-9]+$`); err != nil {
+		t.Fatalf("parseFlags failed: %v", err)
+	}
+	if !subPattern.MatchString("user123") || subPattern.MatchString("alice") {
+		t.Errorf("Expected the configured pattern, got %s", subPattern)
+	}
+	if err := parseTestFlags(t, "-sub-pattern", `(`); err == nil {
+		t.Error("Expected an error for an invalid pattern")
+	}
+}
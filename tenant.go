@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Issuer per tenant name, from -tenants or the config file's "tenants"
+var tenantIssuers map[string]string
+
+// Tenants served under /tenants/{tenant}/, built from tenantIssuers by initTenants
+var tenants map[string]*tenant
+
+// A logical issuer with its own key ring, sharing users, settings and the
+// signing algorithm with the server's own issuer
+type tenant struct {
+	Name   string
+	Issuer string
+
+	mu   sync.RWMutex
+	keys []*KeyPair
+}
+
+// Generates a first signing key for every configured tenant
+func initTenants() error {
+	m := make(map[string]*tenant, len(tenantIssuers))
+	for name, iss := range tenantIssuers {
+		t := &tenant{Name: name, Issuer: iss}
+		if err := t.rotate(nowFunc()); err != nil {
+			return fmt.Errorf("tenant %s: %w", name, err)
+		}
+		m[name] = t
+	}
+	tenants = m
+	return nil
+}
+
+// Adds a new signing key and drops keys past expiry plus jwksGrace
+func (t *tenant) rotate(now time.Time) error {
+	kp, err := withKeygenSlot(true, func() (*KeyPair, error) {
+		return generateWithRetry(context.Background(), now.Add(keyLifetime), rsaBits)
+	})
+	if err == nil {
+		err = validateKeyPair(kp)
+	}
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.keys = slices.DeleteFunc(append(slices.Clone(t.keys), kp), func(k *KeyPair) bool {
+		return !now.Before(k.ExpiresAt.Add(jwksGrace))
+	})
+	return nil
+}
+
+// Newest key of the ring while it is unexpired
+func (t *tenant) signingKeyAt(now time.Time) (*KeyPair, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if len(t.keys) == 0 || !now.Before(t.keys[len(t.keys)-1].ExpiresAt) {
+		return nil, false
+	}
+	return t.keys[len(t.keys)-1], true
+}
+
+// Keys still published at now, ordered like publishedKeys
+func (t *tenant) publishedKeys(now time.Time) []*KeyPair {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	var keys []*KeyPair
+	for _, kp := range t.keys {
+		if now.Before(kp.ExpiresAt.Add(jwksGrace)) {
+			keys = append(keys, kp)
+		}
+	}
+	return sortKeys(keys)
+}
+
+// Rotates every tenant's key ring, logging each failure; a failed tenant
+// keeps signing with its current key
+func rotateTenants(now time.Time) {
+	for name, t := range tenants {
+		if err := t.rotate(now); err != nil {
+			logger.Error("tenant key rotation failed", "tenant", name, "error", err)
+		}
+	}
+}
+
+// Tenant named by the {tenant} path segment
+func tenantFor(r *http.Request) (*tenant, bool) {
+	t, ok := tenants[r.PathValue("tenant")]
+	return t, ok
+}
+
+var errUnknownTenant = errors.New("unknown tenant")
+
+// Serves a tenant's JWKS at /tenants/{tenant}/.well-known/jwks.json
+func tenantJWKSHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		respondError(w, 405, errors.New("use GET"))
+		return
+	}
+	t, ok := tenantFor(r)
+	if !ok {
+		respondError(w, 404, errUnknownTenant)
+		return
+	}
+	jwksRequests.Inc()
+	now := nowFunc()
+	published := t.publishedKeys(now)
+	etag := jwksETag(published)
+	setJWKSCacheHeaders(w, etag, jwksMaxAge(published, now))
+	if etagMatches(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", jwksContentType(r))
+	if err := streamJWKS(w, jwkSeq(published, nil)); err != nil {
+		logResponseError(w, r, err)
+	}
+}
+
+// Issues a token from a tenant's key ring at /tenants/{tenant}/auth
+func tenantAuthHandler(w http.ResponseWriter, r *http.Request) {
+	t, ok := tenantFor(r)
+	if !ok {
+		respondError(w, 404, errUnknownTenant)
+		return
+	}
+	serveAuth(w, r, t)
+}
+
+// Registers a comma-separated name=value flag such as "acme=https://acme.example", resetting *p
+func stringMapVar(fs *flag.FlagSet, p *map[string]string, name, usage string) {
+	*p = nil
+	fs.Func(name, usage, func(s string) error {
+		m := map[string]string{}
+		for _, item := range splitList(s) {
+			key, value, ok := strings.Cut(item, "=")
+			if !ok || key == "" {
+				return fmt.Errorf("expected name=value, got %q", item)
+			}
+			m[key] = value
+		}
+		*p = m
+		return nil
+	})
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Configure and initialize two tenants for the duration of a test
+func setupTenants(t *testing.T) {
+	t.Helper()
+	tenantIssuers = map[string]string{"acme": "https://acme.example", "globex": "https://globex.example"}
+	t.Cleanup(func() { tenantIssuers, tenants = nil, nil })
+	if err := initTenants(); err != nil {
+		t.Fatalf("initTenants failed: %v", err)
+	}
+}
+
+// Fetch a tenant's JWKS through the router
+func tenantJWKS(t *testing.T, name string) JWKS {
+	t.Helper()
+	w := httptest.NewRecorder()
+	newRouter().ServeHTTP(w, httptest.NewRequest("GET", "/tenants/"+name+"/.well-known/jwks.json", nil))
+	var jwks JWKS
+	if w.Code != 200 || json.Unmarshal(w.Body.Bytes(), &jwks) != nil || len(jwks.Keys) != 1 {
+		t.Fatalf("Expected a one-key JWKS for %s, got %d %s", name, w.Code, w.Body)
+	}
+	return jwks
+}
+
+// Test each tenant publishes its own key ring
+func TestTenants_DistinctKids(t *testing.T) {
+	setupTenants(t)
+	acme, globex := tenantJWKS(t, "acme"), tenantJWKS(t, "globex")
+	if acme.Keys[0].Kid == globex.Keys[0].Kid {
+		t.Errorf("Expected distinct kids, both got %s", acme.Keys[0].Kid)
+	}
+}
+
+// Test tenant tokens carry the tenant's iss and verify against its JWKS key
+func TestTenants_TokenIssuer(t *testing.T) {
+	setupTenants(t)
+	for name, iss := range tenantIssuers {
+		w := httptest.NewRecorder()
+		newRouter().ServeHTTP(w, loginRequest("/tenants/"+name+"/auth", "user123", "password123"))
+		if w.Code != 200 {
+			t.Fatalf("Expected 200 for %s, got %d: %s", name, w.Code, w.Body)
+		}
+		resp := authResponse(t, w.Body.Bytes())
+		kp, _ := tenants[name].signingKeyAt(time.Now())
+		if resp["kid"] != kp.Kid || resp["refresh_token"] != "" {
+			t.Errorf("Unexpected response for %s: %v", name, resp)
+		}
+		claims := jwt.MapClaims{}
+		if _, err := jwt.ParseWithClaims(resp["token"], claims, func(*jwt.Token) (any, error) {
+			return kp.verificationKey(), nil
+		}); err != nil {
+			t.Fatalf("Token for %s did not verify: %v", name, err)
+		}
+		if claims["iss"] != iss {
+			t.Errorf("Expected iss %s, got %v", iss, claims["iss"])
+		}
+	}
+}
+
+// Test unknown tenants are 404 on both endpoints
+func TestTenants_Unknown(t *testing.T) {
+	setupTenants(t)
+	w := httptest.NewRecorder()
+	newRouter().ServeHTTP(w, httptest.NewRequest("GET", "/tenants/initech/.well-known/jwks.json", nil))
+	assertEnvelopeError(t, w, 404)
+
+	w = httptest.NewRecorder()
+	newRouter().ServeHTTP(w, loginRequest("/tenants/initech/auth", "user123", "password123"))
+	assertEnvelopeError(t, w, 404)
+}
+
+// Test -tenants parses name=issuer pairs and rejects an empty issuer
+func TestParseFlags_Tenants(t *testing.T) {
+	if err := parseTestFlags(t, "-tenants", "acme=https://acme.example, globex=https://globex.example"); err != nil {
+		t.Fatalf("parseFlags failed: %v", err)
+	}
+	if len(tenantIssuers) != 2 || tenantIssuers["globex"] != "https://globex.example" {
+		t.Errorf("Unexpected tenants: %v", tenantIssuers)
+	}
+	if err := parseTestFlags(t, "-tenants", "acme="); err == nil {
+		t.Error("Expected an error for a tenant without an issuer")
+	}
+}
+
+// Test a failed tenant rotation is logged with the tenant name and keeps its key
+func TestRotateTenants_FailureLogged(t *testing.T) {
+	setupTenants(t)
+	kid := tenantJWKS(t, "acme").Keys[0].Kid
+	var buf bytes.Buffer
+	originalLogger, originalGen, originalAttempts := logger, generateKeyPairFunc, keygenAttempts
+	logger = slog.New(slog.NewJSONHandler(&buf, nil))
+	generateKeyPairFunc = func(time.Time, int) (*KeyPair, error) { return nil, errors.New("no entropy") }
+	keygenAttempts = 1
+	defer func() { logger, generateKeyPairFunc, keygenAttempts = originalLogger, originalGen, originalAttempts }()
+
+	rotateTenants(time.Now())
+	if out := buf.String(); !strings.Contains(out, `"msg":"tenant key rotation failed"`) || !strings.Contains(out, `"tenant":"acme"`) {
+		t.Errorf("Expected a structured failure per tenant, got %s", out)
+	}
+	if got := tenantJWKS(t, "acme").Keys[0].Kid; got != kid {
+		t.Errorf("Expected acme to keep key %s, got %s", kid, got)
+	}
+}
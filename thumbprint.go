@@ -0,0 +1,41 @@
+package main
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+
+	"github.com/google/uuid"
+)
+
+// How kids are assigned to new keys: "uuid" (default) or "thumbprint"
+var kidMode = "uuid"
+
+// RFC 7638 SHA-256 thumbprint over the required members in lexicographic order
+func jwkThumbprint(jwk JWK) string {
+	var canonical string
+	if jwk.Kty == "EC" {
+		canonical = `{"crv":"` + jwk.Crv + `","kty":"EC","x":"` + jwk.X + `","y":"` + jwk.Y + `"}`
+	} else {
+		canonical = `{"e":"` + jwk.E + `","kty":"RSA","n":"` + jwk.N + `"}`
+	}
+	sum := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// RFC 7638 thumbprint of an RSA public key, used as its kid under -kid-mode=thumbprint
+func thumbprintKid(pub *rsa.PublicKey) string {
+	return jwkThumbprint((&KeyPair{PublicKey: pub}).toJWK(useSig))
+}
+
+// Assigns the kid for a freshly generated key according to kidMode
+func assignKid(kp *KeyPair) {
+	switch {
+	case kidMode != "thumbprint":
+		kp.Kid = uuid.New().String()
+	case kp.PublicKey != nil:
+		kp.Kid = thumbprintKid(kp.PublicKey)
+	default:
+		kp.Kid = jwkThumbprint(kp.toJWK(useSig))
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// Test the RFC 7638 section 3.1 example thumbprint
+func TestJWKThumbprint_RFCExample(t *testing.T) {
+	jwk := JWK{
+		Kty: "RSA",
+		N:   "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+		E:   "AQAB",
+	}
+	if got := jwkThumbprint(jwk); got != "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs" {
+		t.Errorf("Unexpected thumbprint %s", got)
+	}
+}
+
+// Test keys with the same modulus and exponent share a thumbprint
+func TestThumbprintKid_SameKey(t *testing.T) {
+	kp, _ := generateKeyPair(time.Now().Add(time.Hour), 2048)
+	clone := &rsa.PublicKey{N: new(big.Int).Set(kp.PublicKey.N), E: kp.PublicKey.E}
+	if thumbprintKid(kp.PublicKey) != thumbprintKid(clone) {
+		t.Error("Expected identical thumbprints for identical public keys")
+	}
+	other, _ := generateKeyPair(time.Now().Add(time.Hour), 2048)
+	if thumbprintKid(kp.PublicKey) == thumbprintKid(other.PublicKey) {
+		t.Error("Expected different thumbprints for different keys")
+	}
+}
+
+// Test thumbprint kid mode is applied at generation time
+func TestGenerateKeyPair_ThumbprintMode(t *testing.T) {
+	kidMode = "thumbprint"
+	defer func() { kidMode = "uuid" }()
+
+	kp, _ := generateKeyPair(time.Now().Add(time.Hour), 2048)
+	if kp.Kid != thumbprintKid(kp.PublicKey) {
+		t.Errorf("Expected thumbprint kid, got %s", kp.Kid)
+	}
+	if raw, err := base64.RawURLEncoding.DecodeString(kp.Kid); err != nil || len(raw) != 32 {
+		t.Errorf("Expected base64url SHA-256 kid, got %s", kp.Kid)
+	}
+}
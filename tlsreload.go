@@ -0,0 +1,67 @@
+package main
+
+import (
+	"crypto/tls"
+	"os"
+	"sync"
+	"time"
+)
+
+// TLS certificate and key paths; both or neither must be set
+var tlsCertFile, tlsKeyFile string
+
+// Minimum time between checks of the certificate files for changes
+const certCheckInterval = 5 * time.Second
+
+// Serves a certificate from disk, reloading it when either file changes
+type certReloader struct {
+	certPath, keyPath string
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	lastCheck time.Time
+}
+
+func newCertReloader(certPath, keyPath string) (*certReloader, error) {
+	cr := &certReloader{certPath: certPath, keyPath: keyPath}
+	if err := cr.reload(); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+func (cr *certReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(cr.certPath, cr.keyPath)
+	if err != nil {
+		return err
+	}
+	cr.cert = &cert
+	cr.modTime = cr.latestModTime()
+	return nil
+}
+
+func (cr *certReloader) latestModTime() time.Time {
+	var latest time.Time
+	for _, p := range []string{cr.certPath, cr.keyPath} {
+		if fi, err := os.Stat(p); err == nil && fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+	return latest
+}
+
+// tls.Config.GetCertificate hook; keeps serving the old cert if a reload fails
+func (cr *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	if now := time.Now(); now.Sub(cr.lastCheck) >= certCheckInterval {
+		cr.lastCheck = now
+		if cr.latestModTime().After(cr.modTime) {
+			if err := cr.reload(); err != nil {
+				logger.Error("TLS certificate reload failed", "error", err)
+			}
+		}
+	}
+	return cr.cert, nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Write a self-signed certificate for 127.0.0.1 and return its paths and parsed form
+func writeSelfSignedCert(t *testing.T, dir, cn string) (certPath, keyPath string, cert *x509.Certificate) {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalPKCS8PrivateKey(key)
+	certPath, keyPath = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600)
+	cert, _ = x509.ParseCertificate(der)
+	return certPath, keyPath, cert
+}
+
+// Test an HTTPS handshake succeeds with a self-signed certificate
+func TestServe_TLS(t *testing.T) {
+	certPath, keyPath, cert := writeSelfSignedCert(t, t.TempDir(), "jwks-test")
+	certs, err := newCertReloader(certPath, keyPath)
+	if err != nil {
+		t.Fatalf("Failed to load cert: %v", err)
+	}
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	srv := &http.Server{
+		Handler:   http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(204) }),
+		TLSConfig: &tls.Config{GetCertificate: certs.GetCertificate},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- serve(ctx, srv, ln, time.Second) }()
+	defer func() { cancel(); <-done }()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	resp, err := client.Get("https://" + ln.Addr().String())
+	if err != nil {
+		t.Fatalf("HTTPS request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 204 || resp.TLS == nil {
+		t.Errorf("Expected 204 over TLS, got %d", resp.StatusCode)
+	}
+}
+
+// Test a replaced certificate on disk is picked up without a restart
+func TestCertReloader_Reload(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath, _ := writeSelfSignedCert(t, dir, "first")
+	certs, _ := newCertReloader(certPath, keyPath)
+
+	writeSelfSignedCert(t, dir, "second")
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(certPath, future, future)
+	certs.lastCheck = time.Time{}
+
+	got, _ := certs.GetCertificate(nil)
+	leaf, _ := x509.ParseCertificate(got.Certificate[0])
+	if leaf.Subject.CommonName != "second" {
+		t.Errorf("Expected reloaded cert, got CN %q", leaf.Subject.CommonName)
+	}
+}
+
+// Test a lone -tls-cert is rejected at startup
+func TestParseFlags_TLSPair(t *testing.T) {
+	if err := parseTestFlags(t, "-tls-cert", "cert.pem"); err == nil {
+		t.Error("Expected error when -tls-key is missing")
+	}
+}
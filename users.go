@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Login payload accepted by /auth
+type Credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	TTL      string `json:"ttl,omitempty"`
+	Audience string `json:"audience,omitempty"`
+}
+
+// In-memory user store mapping usernames to bcrypt hashes
+var userStore = map[string][]byte{}
+
+// htpasswd-style file of username:bcrypt-hash lines
+var usersFile string
+
+// Seed the well-known demo account used by the README examples; local development only
+var demoUser bool
+
+const (
+	demoUsername = "user123"
+	demoPassword = "password123"
+)
+
+// Hash compared against when the user is unknown, so lookups take similar time
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+
+func addUser(username, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	userStore[username] = hash
+	return nil
+}
+
+func checkCredentials(username, password string) bool {
+	hash, ok := userStore[username]
+	if !ok {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil
+}
+
+// Loads users from usersFile and, with -demo-user, seeds the demo account,
+// warning that anyone knowing the README can log in
+func initUsers() error {
+	if usersFile != "" {
+		if err := loadUsersFile(usersFile); err != nil {
+			return err
+		}
+	}
+	if demoUser {
+		logger.Warn("seeding the demo account; do not use -demo-user in production", "username", demoUsername)
+		return addUser(demoUsername, demoPassword)
+	}
+	if len(userStore) == 0 {
+		logger.Warn("no users configured; /auth rejects every login until -users-file or -demo-user is set")
+	}
+	return nil
+}
+
+// Adds every username:bcrypt-hash line of path to the user store, skipping
+// blank lines and # comments. Nothing is added if any line is malformed.
+func loadUsersFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	users := map[string][]byte{}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		name, hash, ok := strings.Cut(text, ":")
+		if !ok || name == "" {
+			return fmt.Errorf("%s:%d: expected username:bcrypt-hash", path, line)
+		}
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return fmt.Errorf("%s:%d: invalid bcrypt hash for %q: %w", path, line, name, err)
+		}
+		users[name] = []byte(hash)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	for name, hash := range users {
+		userStore[name] = hash
+	}
+	return nil
+}
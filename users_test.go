@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Write a users file into a temp dir and return its path
+func writeUsersFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "users")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func bcryptHash(t *testing.T, password string) string {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(hash)
+}
+
+// Test a well-formed users file is loaded
+func TestLoadUsersFile(t *testing.T) {
+	path := writeUsersFile(t, "carol:"+bcryptHash(t, "s3cret")+"\ndave:"+bcryptHash(t, "hunter2")+"\n")
+	if err := loadUsersFile(path); err != nil {
+		t.Fatalf("loadUsersFile failed: %v", err)
+	}
+	if !checkCredentials("carol", "s3cret") || !checkCredentials("dave", "hunter2") {
+		t.Error("Expected both users to authenticate")
+	}
+	if checkCredentials("carol", "hunter2") {
+		t.Error("Expected wrong password to fail")
+	}
+}
+
+// Test blank lines and comments are skipped
+func TestLoadUsersFile_CommentsAndBlankLines(t *testing.T) {
+	path := writeUsersFile(t, "# staff accounts\n\n   \nerin:"+bcryptHash(t, "pw")+"\n  # trailing comment\n")
+	if err := loadUsersFile(path); err != nil {
+		t.Fatalf("loadUsersFile failed: %v", err)
+	}
+	if !checkCredentials("erin", "pw") {
+		t.Error("Expected erin to authenticate")
+	}
+}
+
+// Test a malformed line is reported with its line number and nothing is loaded
+func TestLoadUsersFile_Malformed(t *testing.T) {
+	path := writeUsersFile(t, "frank:"+bcryptHash(t, "pw")+"\n# ok\nnot-a-valid-line\n")
+	err := loadUsersFile(path)
+	if err == nil || !strings.Contains(err.Error(), ":3:") {
+		t.Fatalf("Expected an error naming line 3, got %v", err)
+	}
+	if _, ok := userStore["frank"]; ok {
+		t.Error("Expected no users loaded from a malformed file")
+	}
+	path = writeUsersFile(t, "grace:not-a-hash\n")
+	if err := loadUsersFile(path); err == nil || !strings.Contains(err.Error(), ":1:") {
+		t.Errorf("Expected an invalid hash error on line 1, got %v", err)
+	}
+}
+
+// Test the demo account is only seeded with -demo-user, and a warning is logged either way
+func TestInitUsers_DemoUser(t *testing.T) {
+	var buf bytes.Buffer
+	originalLogger, originalStore := logger, userStore
+	logger = slog.New(slog.NewJSONHandler(&buf, nil))
+	defer func() { logger, userStore, demoUser = originalLogger, originalStore, false }()
+
+	userStore = map[string][]byte{}
+	if err := initUsers(); err != nil {
+		t.Fatal(err)
+	}
+	if checkCredentials(demoUsername, demoPassword) {
+		t.Error("Expected no demo account without -demo-user")
+	}
+	if !strings.Contains(buf.String(), "no users configured") {
+		t.Errorf("Expected a warning about having no users, got %s", buf.String())
+	}
+
+	buf.Reset()
+	demoUser = true
+	if err := initUsers(); err != nil {
+		t.Fatal(err)
+	}
+	if !checkCredentials(demoUsername, demoPassword) {
+		t.Error("Expected the demo account with -demo-user")
+	}
+	if !strings.Contains(buf.String(), `"level":"WARN"`) || !strings.Contains(buf.String(), "demo account") {
+		t.Errorf("Expected a warning about the demo account, got %s", buf.String())
+	}
+}
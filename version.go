@@ -0,0 +1,24 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Build information, set with -ldflags "-X main.version=... -X main.commit=... -X main.buildDate=..."
+var (
+	version   = "dev"
+	commit    = "unknown"
+	buildDate = "unknown"
+)
+
+// Reports which build is running
+func versionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		respondError(w, 405, errors.New("use GET"))
+		return
+	}
+	if err := respondJSON(w, 200, map[string]string{"version": version, "commit": commit, "build_date": buildDate}); err != nil {
+		logResponseError(w, r, err)
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+// Test /version reports the defaults when built without ldflags
+func TestVersionHandler_Defaults(t *testing.T) {
+	w := httptest.NewRecorder()
+	newRouter().ServeHTTP(w, httptest.NewRequest("GET", "/version", nil))
+	if w.Code != 200 {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	var resp map[string]string
+	envelopeData(t, w.Body.Bytes(), &resp)
+	if resp["version"] != "dev" || resp["commit"] != "unknown" || resp["build_date"] != "unknown" {
+		t.Errorf("Unexpected build info: %v", resp)
+	}
+}
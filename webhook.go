@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// URL that receives a POST describing the new key set after each rotation
+var rotationWebhook string
+
+// Retry policy for webhook deliveries
+var (
+	webhookAttempts = 3
+	webhookBackoff  = time.Second
+	webhookClient   = &http.Client{Timeout: 10 * time.Second}
+)
+
+// Body POSTed to the rotation webhook
+type rotationEvent struct {
+	RotatedAt time.Time `json:"rotated_at"`
+	Added     []string  `json:"added"`
+	Removed   []string  `json:"removed"`
+	JWKS      JWKS      `json:"jwks"`
+}
+
+// Builds the event for a change in the published keys from before to after
+func newRotationEvent(now time.Time, before, after []*KeyPair) rotationEvent {
+	kids := func(keys []*KeyPair) []string {
+		out := make([]string, 0, len(keys))
+		for _, kp := range keys {
+			out = append(out, kp.Kid)
+		}
+		return out
+	}
+	old, cur := kids(before), kids(after)
+	ev := rotationEvent{RotatedAt: now.UTC(), Added: []string{}, Removed: []string{}, JWKS: buildJWKS(after, publishedEncKey(now))}
+	for _, kid := range cur {
+		if !slices.Contains(old, kid) {
+			ev.Added = append(ev.Added, kid)
+		}
+	}
+	for _, kid := range old {
+		if !slices.Contains(cur, kid) {
+			ev.Removed = append(ev.Removed, kid)
+		}
+	}
+	return ev
+}
+
+// Sends ev to the rotation webhook in the background so rotation never waits on it
+func notifyRotation(ev rotationEvent) {
+	if rotationWebhook == "" {
+		return
+	}
+	body, err := json.Marshal(ev)
+	if err != nil {
+		logger.Error("rotation webhook encoding failed", "error", err)
+		return
+	}
+	go deliverWebhook(rotationWebhook, body)
+}
+
+// POSTs body to url up to webhookAttempts times, doubling the delay from
+// webhookBackoff after each failure
+func deliverWebhook(url string, body []byte) error {
+	delay := webhookBackoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = postWebhook(url, body); err == nil {
+			return nil
+		}
+		if attempt >= webhookAttempts {
+			break
+		}
+		logger.Warn("rotation webhook failed, retrying", "attempt", attempt, "delay", delay, "error", err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+	logger.Error("rotation webhook failed", "attempts", webhookAttempts, "error", err)
+	return err
+}
+
+func postWebhook(url string, body []byte) error {
+	resp, err := webhookClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
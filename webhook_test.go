@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Test rotation POSTs the new kid and key set without waiting for the receiver
+func TestRotateKeys_Webhook(t *testing.T) {
+	bodies := make(chan []byte, 1)
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- body
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+	rotationWebhook = srv.URL
+	defer func() { rotationWebhook = "" }()
+	old, _ := generateKeyPair(time.Now().Add(time.Hour), 2048)
+	validKey = old
+	setKeyRing([]*KeyPair{old})
+
+	kp, err := rotateKeys(true)
+	if err != nil {
+		t.Fatalf("rotateKeys failed: %v", err)
+	}
+
+	var ev rotationEvent
+	select {
+	case body := <-bodies:
+		if err := json.Unmarshal(body, &ev); err != nil {
+			t.Fatalf("Invalid webhook body: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Webhook was not called")
+	}
+	if !slices.Equal(ev.Added, []string{kp.Kid}) || len(ev.Removed) != 0 {
+		t.Errorf("Expected added [%s] and nothing removed, got %+v / %+v", kp.Kid, ev.Added, ev.Removed)
+	}
+	if !slices.ContainsFunc(ev.JWKS.Keys, func(k JWK) bool { return k.Kid == kp.Kid }) {
+		t.Errorf("Expected the new kid in the webhook JWKS, got %+v", ev.JWKS.Keys)
+	}
+}
+
+// Test failed deliveries are retried until one succeeds
+func TestDeliverWebhook_Retries(t *testing.T) {
+	webhookBackoff = time.Millisecond
+	defer func() { webhookBackoff = time.Second }()
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) < 3 {
+			w.WriteHeader(500)
+		}
+	}))
+	defer srv.Close()
+
+	if err := deliverWebhook(srv.URL, []byte("{}")); err != nil || hits.Load() != 3 {
+		t.Errorf("Expected success on the third attempt, got %v after %d attempts", err, hits.Load())
+	}
+}
+
+// Test delivery gives up after webhookAttempts failures
+func TestDeliverWebhook_GivesUp(t *testing.T) {
+	webhookAttempts, webhookBackoff = 2, time.Millisecond
+	defer func() { webhookAttempts, webhookBackoff = 3, time.Second }()
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(503)
+	}))
+	defer srv.Close()
+
+	if err := deliverWebhook(srv.URL, []byte("{}")); err == nil || hits.Load() != 2 {
+		t.Errorf("Expected failure after 2 attempts, got %v after %d attempts", err, hits.Load())
+	}
+}
+
+// Test -rotation-webhook must be an http(s) URL
+func TestParseFlags_InvalidRotationWebhook(t *testing.T) {
+	if err := parseTestFlags(t, "-rotation-webhook", "ftp://example.com/hook"); err == nil {
+		t.Error("Expected a non-HTTP webhook URL to be rejected")
+	}
+}
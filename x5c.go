@@ -0,0 +1,42 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"time"
+)
+
+// Publish a self-signed certificate for each key as x5c/x5t#S256
+var emitX5C bool
+
+// Wraps the key's public half in a minimal self-signed certificate valid until the key expires
+func selfSignedCert(kp *KeyPair) ([]byte, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	notBefore := nowFunc().Add(-time.Minute)
+	if kp.ExpiresAt.Before(notBefore) {
+		notBefore = kp.ExpiresAt.Add(-time.Hour)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: kp.Kid},
+		NotBefore:             notBefore,
+		NotAfter:              kp.ExpiresAt,
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}
+	return x509.CreateCertificate(rand.Reader, tmpl, tmpl, kp.verificationKey(), kp.signingKey())
+}
+
+// Adds the certificate chain and its SHA-256 thumbprint to a JWK
+func addX5C(jwk *JWK, der []byte) {
+	sum := sha256.Sum256(der)
+	jwk.X5C = []string{base64.StdEncoding.EncodeToString(der)}
+	jwk.X5TS256 = base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// Test x5c wraps the same public key published in the JWK
+func TestToJWK_X5C(t *testing.T) {
+	emitX5C = true
+	defer func() { emitX5C = false }()
+
+	kp, err := generateKeyPair(time.Now().Add(time.Hour), 2048)
+	if err != nil {
+		t.Fatalf("Key generation failed: %v", err)
+	}
+	jwk := kp.toJWK(useSig)
+	if len(jwk.X5C) != 1 {
+		t.Fatalf("Expected one x5c entry, got %d", len(jwk.X5C))
+	}
+	der, err := base64.StdEncoding.DecodeString(jwk.X5C[0])
+	if err != nil {
+		t.Fatalf("x5c is not standard base64: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("x5c is not a certificate: %v", err)
+	}
+	pub, ok := cert.PublicKey.(*rsa.PublicKey)
+	n, _ := base64.RawURLEncoding.DecodeString(jwk.N)
+	if !ok || pub.N.Cmp(new(big.Int).SetBytes(n)) != 0 {
+		t.Error("Certificate public key does not match JWK modulus")
+	}
+	if err := cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature); err != nil {
+		t.Errorf("Expected self-signed certificate: %v", err)
+	}
+	sum := sha256.Sum256(der)
+	if jwk.X5TS256 != base64.RawURLEncoding.EncodeToString(sum[:]) {
+		t.Errorf("Unexpected x5t#S256 %q", jwk.X5TS256)
+	}
+}
+
+// Test x5c is omitted by default
+func TestToJWK_NoX5CByDefault(t *testing.T) {
+	kp, _ := generateKeyPair(time.Now().Add(time.Hour), 2048)
+	if jwk := kp.toJWK(useSig); jwk.X5C != nil || jwk.X5TS256 != "" {
+		t.Errorf("Expected no certificate fields, got %+v", jwk)
+	}
+}